@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Passwords must be long enough to resist guessing; the upper bound only
+// keeps hashing cheap.
+const (
+	minPasswordLength = 10
+	maxPasswordLength = 256
+	maxUsernameLength = 32
+)
+
+// account is a console user. Usernames are lower case.
+type account struct {
+	Username     string `json:"username"`
+	Role         role   `json:"role"`
+	PasswordHash string `json:"passwordHash"`
+}
+
+func (r role) MarshalText() ([]byte, error) {
+	if _, ok := roleNames[r]; !ok {
+		return nil, fmt.Errorf("unknown role %d", int(r))
+	}
+	return []byte(r.String()), nil
+}
+
+func (r *role) UnmarshalText(text []byte) error {
+	parsed, ok := parseRole(string(text))
+	if !ok {
+		return fmt.Errorf("unknown role %q", text)
+	}
+	*r = parsed
+	return nil
+}
+
+// accountStore keeps the console's accounts in a JSON file, rewritten
+// atomically on every change like the catalog store.
+type accountStore struct {
+	mu       sync.RWMutex
+	path     string
+	accounts []account
+}
+
+type accountsFile struct {
+	Accounts []account `json:"accounts"`
+}
+
+// openAccounts reads the accounts file. A missing file is an empty store;
+// it is created with the first account.
+func openAccounts(path string) (*accountStore, error) {
+	s := &accountStore{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file accountsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode accounts %s: %w", path, err)
+	}
+	s.accounts = file.Accounts
+	return s, nil
+}
+
+func (s *accountStore) get(username string) (account, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, acct := range s.accounts {
+		if acct.Username == username {
+			return acct, true
+		}
+	}
+	return account{}, false
+}
+
+// list returns the accounts sorted by username.
+func (s *accountStore) list() []account {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	accounts := append([]account(nil), s.accounts...)
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Username < accounts[j].Username })
+	return accounts
+}
+
+// put adds acct or replaces the account with its username.
+func (s *accountStore) put(acct account) error {
+	return s.update(func(accounts []account) ([]account, error) {
+		for i := range accounts {
+			if accounts[i].Username == acct.Username {
+				accounts[i] = acct
+				return accounts, nil
+			}
+		}
+		return append(accounts, acct), nil
+	})
+}
+
+func (s *accountStore) remove(username string) error {
+	return s.update(func(accounts []account) ([]account, error) {
+		for i := range accounts {
+			if accounts[i].Username == username {
+				return append(accounts[:i], accounts[i+1:]...), nil
+			}
+		}
+		return nil, errNotFound(fmt.Sprintf("There is no account %q.", username))
+	})
+}
+
+// update applies change to a copy of the accounts and writes the result.
+// Once there is an admin, a change may not remove the last one, so the
+// console can never lock itself out.
+func (s *accountStore) update(change func([]account) ([]account, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, err := change(append([]account(nil), s.accounts...))
+	if err != nil {
+		return err
+	}
+	if countAdmins(s.accounts) > 0 && countAdmins(next) == 0 {
+		return validationError{"": "At least one account must keep the admin role."}
+	}
+	data, err := json.MarshalIndent(accountsFile{Accounts: next}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	s.accounts = next
+	return nil
+}
+
+func countAdmins(accounts []account) int {
+	n := 0
+	for _, acct := range accounts {
+		if acct.Role == roleAdmin {
+			n++
+		}
+	}
+	return n
+}
+
+// newAccount validates a username, role and password and hashes the
+// password. Problems are reported per form field.
+func newAccount(username, roleName, password string) (account, error) {
+	errs := validationError{}
+	if msg := usernameProblem(username); msg != "" {
+		errs["username"] = msg
+	}
+	r, ok := parseRole(roleName)
+	if !ok {
+		errs["role"] = "Choose viewer, editor or admin."
+	}
+	if msg := passwordProblem(password); msg != "" {
+		errs["password"] = msg
+	}
+	if len(errs) > 0 {
+		return account{}, errs
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return account{}, err
+	}
+	return account{Username: username, Role: r, PasswordHash: hash}, nil
+}
+
+func usernameProblem(username string) string {
+	if username == "" || len(username) > maxUsernameLength {
+		return fmt.Sprintf("Username must be 1 to %d characters.", maxUsernameLength)
+	}
+	for _, c := range username {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return "Username may only use lower-case letters, digits, dots, dashes and underscores."
+		}
+	}
+	return ""
+}
+
+func passwordProblem(password string) string {
+	if n := len([]rune(password)); n < minPasswordLength || n > maxPasswordLength {
+		return fmt.Sprintf("Password must be %d to %d characters.", minPasswordLength, maxPasswordLength)
+	}
+	return ""
+}
+
+// usersHandler serves the account pages under /admin/users. Only admins
+// reach it.
+func (app *App) usersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/admin/users" {
+		if !app.allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		app.renderUsers(w, r, http.StatusOK, nil, "")
+		return
+	}
+	if !app.allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	var err error
+	if r.URL.Path == "/admin/users/new" {
+		err = app.createAccount(r.PostForm.Get("username"), r.PostForm.Get("role"), r.PostForm.Get("password"))
+	} else if params, ok := matchRoute("/admin/users/{name}/delete", r.URL.Path); ok {
+		err = app.accounts.remove(params["name"])
+	} else if params, ok := matchRoute("/admin/users/{name}", r.URL.Path); ok {
+		acct, found := app.accounts.get(params["name"])
+		if !found {
+			app.renderError(w, r, errNotFound(fmt.Sprintf("There is no account %q.", params["name"])))
+			return
+		}
+		err = app.updateAccount(acct, r.PostForm.Get("role"), r.PostForm.Get("password"))
+	} else {
+		app.notFoundHandler(w, r)
+		return
+	}
+
+	var invalid validationError
+	if errors.As(err, &invalid) {
+		app.renderUsers(w, r, http.StatusUnprocessableEntity, invalid, r.PostForm.Get("username"))
+		return
+	}
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *App) createAccount(username, roleName, password string) error {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if _, exists := app.accounts.get(username); exists {
+		return validationError{"username": "There is already an account with this name."}
+	}
+	acct, err := newAccount(username, roleName, password)
+	if err != nil {
+		return err
+	}
+	return app.accounts.put(acct)
+}
+
+// updateAccount changes acct's role and, when password is not empty, its
+// password.
+func (app *App) updateAccount(acct account, roleName, password string) error {
+	r, ok := parseRole(roleName)
+	if !ok {
+		return validationError{"role": "Choose viewer, editor or admin."}
+	}
+	acct.Role = r
+	if password != "" {
+		if msg := passwordProblem(password); msg != "" {
+			return validationError{"password": msg}
+		}
+		hash, err := hashPassword(password)
+		if err != nil {
+			return err
+		}
+		acct.PasswordHash = hash
+	}
+	return app.accounts.put(acct)
+}
+
+func (app *App) renderUsers(w http.ResponseWriter, r *http.Request, status int, errs validationError, username string) {
+	data := struct {
+		adminPage
+		Accounts []account
+		Roles    []string
+		Username string
+		Errors   validationError
+	}{
+		adminPage: app.adminPage(r, "Accounts - Aurora Cars"),
+		Accounts:  app.accounts.list(),
+		Roles:     []string{roleViewer.String(), roleEditor.String(), roleAdmin.String()},
+		Username:  username,
+		Errors:    errs,
+	}
+	app.renderStatus(w, r, status, "admin_users.html", data)
+}
+
+// runUser manages console accounts from the command line, which is how the
+// first admin is created:
+//
+//	cars user add -role admin alice
+//	cars user remove alice
+//	cars user list
+//
+// add reads the password from the first line of stdin and replaces any
+// existing account with the same name.
+func runUser(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: user add|remove|list [-accounts file] [-role role] [name]")
+	}
+	command := args[0]
+	fs := flag.NewFlagSet("user "+command, flag.ContinueOnError)
+	path := fs.String("accounts", defaultConfig().Admin.AccountsFile, "accounts file")
+	roleName := fs.String("role", roleEditor.String(), "role for add: viewer, editor or admin")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	accounts, err := openAccounts(*path)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "list":
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		for _, acct := range accounts.list() {
+			fmt.Fprintf(tw, "%s\t%s\n", acct.Username, acct.Role)
+		}
+		return tw.Flush()
+	case "add", "remove":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("user %s needs exactly one account name", command)
+		}
+	default:
+		return fmt.Errorf("unknown user command %q, use add, remove or list", command)
+	}
+
+	username := strings.ToLower(fs.Arg(0))
+	if command == "remove" {
+		return accounts.remove(username)
+	}
+	fmt.Fprintf(stdout, "Password for %s: ", username)
+	password, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("no password given: %w", err)
+	}
+	fmt.Fprintln(stdout)
+	acct, err := newAccount(username, *roleName, strings.TrimRight(password, "\r\n"))
+	if err != nil {
+		return err
+	}
+	return accounts.put(acct)
+}
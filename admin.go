@@ -0,0 +1,625 @@
+package main
+
+import (
+	"cars/structs"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTextLength bounds every free-text field in the admin forms.
+const maxTextLength = 100
+
+// validationError maps form field names to what is wrong with them. The
+// empty name holds problems with the record as a whole.
+type validationError map[string]string
+
+func (e validationError) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	messages := make([]string, len(names))
+	for i, name := range names {
+		messages[i] = e[name]
+	}
+	return strings.Join(messages, " ")
+}
+
+type formField struct {
+	Name    string
+	Label   string
+	Type    string
+	Value   string
+	Options []formOption
+	Error   string
+}
+
+type formOption struct {
+	Value string
+	Label string
+}
+
+// adminResource is one kind of catalog record the console can edit. Records
+// travel between the forms and the catalog as url.Values keyed by field name.
+type adminResource struct {
+	Singular string
+	fields   func(c catalog) []formField
+	values   func(c catalog, id int) (url.Values, bool)
+	save     func(c *catalog, id int, values url.Values) error
+	delete   func(c *catalog, id int) error
+}
+
+var adminResources = map[string]adminResource{
+	"manufacturers": {
+		Singular: "manufacturer",
+		fields: func(catalog) []formField {
+			return append([]formField{
+				{Name: "name", Label: "Name", Type: "text"},
+				{Name: "country", Label: "Country", Type: "text"},
+				{Name: "foundingYear", Label: "Founding year", Type: "number"},
+			}, publicationFields()...)
+		},
+		values: func(c catalog, id int) (url.Values, bool) {
+			for _, m := range c.Manufacturers {
+				if m.ID == id {
+					values := url.Values{"name": {m.Name}, "country": {m.Country}, "foundingYear": {strconv.Itoa(m.Founded)}}
+					return setPublication(values, m.Status, m.PublishAt), true
+				}
+			}
+			return nil, false
+		},
+		save:   saveManufacturer,
+		delete: deleteManufacturer,
+	},
+	"categories": {
+		Singular: "category",
+		fields: func(catalog) []formField {
+			return append([]formField{{Name: "name", Label: "Name", Type: "text"}}, publicationFields()...)
+		},
+		values: func(c catalog, id int) (url.Values, bool) {
+			for _, category := range c.Categories {
+				if category.ID == id {
+					return setPublication(url.Values{"name": {category.Name}}, category.Status, category.PublishAt), true
+				}
+			}
+			return nil, false
+		},
+		save:   saveCategory,
+		delete: deleteCategory,
+	},
+	"cars": {
+		Singular: "car",
+		fields: func(c catalog) []formField {
+			manufacturers := make([]formOption, len(c.Manufacturers))
+			for i, m := range c.Manufacturers {
+				manufacturers[i] = formOption{Value: strconv.Itoa(m.ID), Label: m.Name}
+			}
+			categories := make([]formOption, len(c.Categories))
+			for i, category := range c.Categories {
+				categories[i] = formOption{Value: strconv.Itoa(category.ID), Label: category.Name}
+			}
+			return append([]formField{
+				{Name: "name", Label: "Name", Type: "text"},
+				{Name: "manufacturerId", Label: "Manufacturer", Type: "select", Options: manufacturers},
+				{Name: "categoryId", Label: "Category", Type: "select", Options: categories},
+				{Name: "year", Label: "Year", Type: "number"},
+				{Name: "engine", Label: "Engine", Type: "text"},
+				{Name: "horsepower", Label: "Horsepower", Type: "number"},
+				{Name: "transmission", Label: "Transmission", Type: "text"},
+				{Name: "drivetrain", Label: "Drivetrain", Type: "text"},
+				{Name: "image", Label: "Image file", Type: "text"},
+			}, publicationFields()...)
+		},
+		values: func(c catalog, id int) (url.Values, bool) {
+			for _, car := range c.CarModels {
+				if car.ID == id {
+					specs := car.Specifications
+					values := url.Values{
+						"name":           {car.Name},
+						"manufacturerId": {strconv.Itoa(car.ManufacturerID)},
+						"categoryId":     {strconv.Itoa(car.CategoryID)},
+						"year":           {strconv.Itoa(car.Year)},
+						"engine":         {specs.Engine},
+						"horsepower":     {strconv.Itoa(specs.Horsepower)},
+						"transmission":   {specs.Transmission},
+						"drivetrain":     {specs.Drivetrain},
+						"image":          {car.Image},
+					}
+					return setPublication(values, car.Status, car.PublishAt), true
+				}
+			}
+			return nil, false
+		},
+		save:   saveCar,
+		delete: deleteCar,
+	},
+}
+
+// formReader parses submitted values, collecting a message for every field
+// that does not validate.
+type formReader struct {
+	values url.Values
+	errs   validationError
+}
+
+func newFormReader(values url.Values) *formReader {
+	return &formReader{values: values, errs: validationError{}}
+}
+
+func (f *formReader) text(name, label string, required bool) string {
+	v := strings.TrimSpace(f.values.Get(name))
+	switch {
+	case required && v == "":
+		f.errs[name] = label + " is required."
+	case len(v) > maxTextLength:
+		f.errs[name] = fmt.Sprintf("%s must be at most %d characters.", label, maxTextLength)
+	}
+	return v
+}
+
+func (f *formReader) number(name, label string, low, high int) int {
+	v, err := strconv.Atoi(strings.TrimSpace(f.values.Get(name)))
+	if err != nil || v < low || v > high {
+		f.errs[name] = fmt.Sprintf("%s must be a whole number from %d to %d.", label, low, high)
+	}
+	return v
+}
+
+func (f *formReader) err() error {
+	if len(f.errs) > 0 {
+		return f.errs
+	}
+	return nil
+}
+
+func saveManufacturer(c *catalog, id int, values url.Values) error {
+	f := newFormReader(values)
+	m := structs.Manufacturer{
+		ID:      id,
+		Name:    f.text("name", "Name", true),
+		Country: f.text("country", "Country", true),
+		Founded: f.number("foundingYear", "Founding year", 1800, time.Now().Year()),
+	}
+	for _, current := range c.Manufacturers {
+		if current.ID == id {
+			m.Status, m.PublishAt = current.Status, current.PublishAt
+		}
+	}
+	m.Status, m.PublishAt = f.publication(m.Status, m.PublishAt)
+	for _, other := range c.Manufacturers {
+		if other.ID != id && strings.EqualFold(other.Name, m.Name) {
+			f.errs["name"] = "Another manufacturer already has this name."
+		}
+	}
+	if err := f.err(); err != nil {
+		return err
+	}
+
+	if id == 0 {
+		for _, other := range c.Manufacturers {
+			m.ID = max(m.ID, other.ID)
+		}
+		m.ID++
+		c.Manufacturers = append(c.Manufacturers, m)
+		return nil
+	}
+	for i := range c.Manufacturers {
+		if c.Manufacturers[i].ID == id {
+			c.Manufacturers[i] = m
+			return nil
+		}
+	}
+	return errNotFound("Manufacturer not found.")
+}
+
+func deleteManufacturer(c *catalog, id int) error {
+	for i, m := range c.Manufacturers {
+		if m.ID != id {
+			continue
+		}
+		if n := countCars(*c, func(car structs.CarModel) bool { return car.ManufacturerID == id }); n > 0 {
+			return validationError{"": fmt.Sprintf("%s still has %s. Delete them or move them to another manufacturer first.", m.Name, countModels(n))}
+		}
+		c.Manufacturers = append(c.Manufacturers[:i], c.Manufacturers[i+1:]...)
+		return nil
+	}
+	return errNotFound("Manufacturer not found.")
+}
+
+func saveCategory(c *catalog, id int, values url.Values) error {
+	f := newFormReader(values)
+	category := structs.Category{ID: id, Name: f.text("name", "Name", true)}
+	for _, current := range c.Categories {
+		if current.ID == id {
+			category.Status, category.PublishAt = current.Status, current.PublishAt
+		}
+	}
+	category.Status, category.PublishAt = f.publication(category.Status, category.PublishAt)
+	for _, other := range c.Categories {
+		if other.ID != id && strings.EqualFold(other.Name, category.Name) {
+			f.errs["name"] = "Another category already has this name."
+		}
+	}
+	if err := f.err(); err != nil {
+		return err
+	}
+
+	if id == 0 {
+		for _, other := range c.Categories {
+			category.ID = max(category.ID, other.ID)
+		}
+		category.ID++
+		c.Categories = append(c.Categories, category)
+		return nil
+	}
+	for i := range c.Categories {
+		if c.Categories[i].ID == id {
+			c.Categories[i] = category
+			return nil
+		}
+	}
+	return errNotFound("Category not found.")
+}
+
+func deleteCategory(c *catalog, id int) error {
+	for i, category := range c.Categories {
+		if category.ID != id {
+			continue
+		}
+		if n := countCars(*c, func(car structs.CarModel) bool { return car.CategoryID == id }); n > 0 {
+			return validationError{"": fmt.Sprintf("%s still has %s. Delete them or move them to another category first.", category.Name, countModels(n))}
+		}
+		c.Categories = append(c.Categories[:i], c.Categories[i+1:]...)
+		return nil
+	}
+	return errNotFound("Category not found.")
+}
+
+func saveCar(c *catalog, id int, values url.Values) error {
+	f := newFormReader(values)
+	car := structs.CarModel{
+		ID:             id,
+		Name:           f.text("name", "Name", true),
+		ManufacturerID: f.number("manufacturerId", "Manufacturer", 1, math.MaxInt32),
+		CategoryID:     f.number("categoryId", "Category", 1, math.MaxInt32),
+		// The first car was built in 1886; allow announced models a
+		// couple of years out.
+		Year: f.number("year", "Year", 1886, time.Now().Year()+2),
+		Specifications: structs.Specifications{
+			Engine:       f.text("engine", "Engine", false),
+			Horsepower:   f.number("horsepower", "Horsepower", 1, 5000),
+			Transmission: f.text("transmission", "Transmission", false),
+			Drivetrain:   f.text("drivetrain", "Drivetrain", false),
+		},
+		Image: f.text("image", "Image file", false),
+	}
+	for _, current := range c.CarModels {
+		if current.ID == id {
+			car.Status, car.PublishAt = current.Status, current.PublishAt
+		}
+	}
+	car.Status, car.PublishAt = f.publication(car.Status, car.PublishAt)
+	if f.errs["manufacturerId"] == "" && !c.hasManufacturer(car.ManufacturerID) {
+		f.errs["manufacturerId"] = "Choose one of the listed manufacturers."
+	}
+	if f.errs["categoryId"] == "" && c.categoryName(car.CategoryID) == "" {
+		f.errs["categoryId"] = "Choose one of the listed categories."
+	}
+	if car.Image != "" && f.errs["image"] == "" {
+		if car.Image != filepath.Base(car.Image) || strings.HasPrefix(car.Image, ".") {
+			f.errs["image"] = "Image file must be a plain file name."
+		} else if !imageExists(car.Image) {
+			f.errs["image"] = fmt.Sprintf("There is no %s in %s.", car.Image, imageDir)
+		}
+	}
+	if err := f.err(); err != nil {
+		return err
+	}
+
+	if id == 0 {
+		for _, other := range c.CarModels {
+			car.ID = max(car.ID, other.ID)
+		}
+		car.ID++
+		c.CarModels = append(c.CarModels, car)
+		return nil
+	}
+	for i := range c.CarModels {
+		if c.CarModels[i].ID == id {
+			c.CarModels[i] = car
+			return nil
+		}
+	}
+	return errNotFound("Car not found.")
+}
+
+func deleteCar(c *catalog, id int) error {
+	for i, car := range c.CarModels {
+		if car.ID == id {
+			c.CarModels = append(c.CarModels[:i], c.CarModels[i+1:]...)
+			return nil
+		}
+	}
+	return errNotFound("Car not found.")
+}
+
+func countCars(c catalog, match func(structs.CarModel) bool) int {
+	n := 0
+	for _, car := range c.CarModels {
+		if match(car) {
+			n++
+		}
+	}
+	return n
+}
+
+func countModels(n int) string {
+	if n == 1 {
+		return "1 model"
+	}
+	return fmt.Sprintf("%d models", n)
+}
+
+func (c catalog) hasManufacturer(id int) bool {
+	for _, m := range c.Manufacturers {
+		if m.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// enableAdmin opens the catalog and account stores, making the catalog file
+// the source of this server's data: it is published now and after every
+// change.
+func (app *App) enableAdmin(cfg AdminConfig) error {
+	key, err := decodeSessionKey(cfg.SessionKey)
+	if err != nil {
+		return err
+	}
+	if cfg.SessionKey == "" {
+		log.Println("admin.sessionKey is not set; console sessions will end when the server restarts")
+	}
+	accounts, err := openAccounts(cfg.AccountsFile)
+	if err != nil {
+		return err
+	}
+	if len(accounts.list()) == 0 {
+		log.Printf("No admin accounts yet; create one with: go run . user add -role admin -accounts %s <name>", cfg.AccountsFile)
+	}
+	store, err := openStore(cfg.DataFile, app.setCatalog)
+	if err != nil {
+		return err
+	}
+	store.audit = &auditLog{path: cfg.AuditFile}
+	app.store, app.accounts, app.sessionKey = store, accounts, key
+	app.setCatalog(store.catalog())
+	return nil
+}
+
+// adminPage holds what every console page needs for its header and forms.
+type adminPage struct {
+	Title string
+	User  *session
+	CSRF  string
+}
+
+func (p adminPage) CanEdit() bool {
+	return p.User != nil && p.User.Role >= roleEditor
+}
+
+func (p adminPage) IsAdmin() bool {
+	return p.User != nil && p.User.Role >= roleAdmin
+}
+
+func (app *App) adminPage(r *http.Request, title string) adminPage {
+	s := sessionFrom(r)
+	return adminPage{Title: title, User: s, CSRF: app.csrfToken(s)}
+}
+
+// adminHandler serves the console under /admin, which only exists while
+// it is enabled in the config. Viewers may look at everything, editors may
+// change the catalog and admins may also manage accounts.
+func (app *App) adminHandler(w http.ResponseWriter, r *http.Request) {
+	if app.store == nil {
+		app.notFoundHandler(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	// Leave room for the other form fields next to an upload.
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+64<<10)
+
+	path := r.URL.Path
+	switch {
+	case path == "/admin/login":
+		app.loginHandler(w, r)
+	case path == "/admin/logout":
+		app.requireRole(roleViewer, app.logoutHandler)(w, r)
+	case path == "/admin":
+		app.requireRole(roleViewer, app.adminIndexHandler)(w, r)
+	case path == "/admin/users" || strings.HasPrefix(path, "/admin/users/"):
+		app.requireRole(roleAdmin, app.usersHandler)(w, r)
+	case path == "/admin/import":
+		app.requireRole(roleEditor, app.importHandler)(w, r)
+	case path == "/admin/audit" || path == "/admin/audit.json":
+		app.requireRole(roleViewer, app.auditHandler)(w, r)
+	case isSafeMethod(r.Method):
+		app.requireRole(roleViewer, app.adminRecordHandler)(w, r)
+	default:
+		app.requireRole(roleEditor, app.adminRecordHandler)(w, r)
+	}
+}
+
+// adminRecordHandler serves the forms for single catalog records.
+func (app *App) adminRecordHandler(w http.ResponseWriter, r *http.Request) {
+	if params, ok := matchRoute("/admin/{kind}/new", r.URL.Path); ok {
+		app.adminEditHandler(w, r, params["kind"], "")
+		return
+	}
+	if params, ok := matchRoute("/admin/{kind}/{id}/delete", r.URL.Path); ok {
+		app.adminDeleteHandler(w, r, params["kind"], params["id"])
+		return
+	}
+	if params, ok := matchRoute("/admin/cars/{id}/image", r.URL.Path); ok {
+		app.adminImageHandler(w, r, params["id"])
+		return
+	}
+	if params, ok := matchRoute("/admin/{kind}/{id}", r.URL.Path); ok {
+		app.adminEditHandler(w, r, params["kind"], params["id"])
+		return
+	}
+	app.notFoundHandler(w, r)
+}
+
+// sameOrigin rejects form posts another site makes the browser send. Clients
+// that send neither Origin nor Referer are not browsers and are let through.
+func sameOrigin(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	if source == "" {
+		return true
+	}
+	u, err := url.Parse(source)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func (app *App) adminIndexHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	// The console lists drafts and everything else the public site leaves
+	// out, so it reads the store rather than the snapshot.
+	c := app.store.catalog()
+	for i, car := range c.CarModels {
+		c.CarModels[i].ManufacturerName = c.getManufacturerNameByID(car.ManufacturerID)
+	}
+	data := struct {
+		adminPage
+		Manufacturers []structs.Manufacturer
+		Categories    []structs.Category
+		CarModels     []structs.CarModel
+	}{
+		adminPage:     app.adminPage(r, "Catalog admin - Aurora Cars"),
+		Manufacturers: c.Manufacturers,
+		Categories:    c.Categories,
+		CarModels:     c.CarModels,
+	}
+	app.render(w, r, "admin.html", data)
+}
+
+func (app *App) adminEditHandler(w http.ResponseWriter, r *http.Request, kind, idStr string) {
+	res, ok := adminResources[kind]
+	if !ok {
+		app.notFoundHandler(w, r)
+		return
+	}
+	if !app.allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	id := 0
+	values := url.Values{}
+	if idStr != "" {
+		var err error
+		if id, err = strconv.Atoi(idStr); err != nil || id < 1 {
+			app.notFoundHandler(w, r)
+			return
+		}
+		if values, ok = res.values(app.store.catalog(), id); !ok {
+			app.renderError(w, r, errNotFound(fmt.Sprintf("There is no %s with ID %d.", res.Singular, id)))
+			return
+		}
+	}
+
+	if r.Method == http.MethodPost {
+		err := app.store.update(sessionFrom(r).Username, func(c *catalog) error { return res.save(c, id, r.PostForm) })
+		var invalid validationError
+		if errors.As(err, &invalid) {
+			app.renderAdminForm(w, r, http.StatusUnprocessableEntity, kind, id, r.PostForm, invalid)
+			return
+		}
+		if err != nil {
+			app.renderError(w, r, err)
+			return
+		}
+		http.Redirect(w, r, "/admin#"+kind, http.StatusSeeOther)
+		return
+	}
+
+	app.renderAdminForm(w, r, http.StatusOK, kind, id, values, nil)
+}
+
+func (app *App) adminDeleteHandler(w http.ResponseWriter, r *http.Request, kind, idStr string) {
+	res, ok := adminResources[kind]
+	id, err := strconv.Atoi(idStr)
+	if !ok || err != nil {
+		app.notFoundHandler(w, r)
+		return
+	}
+	if !app.allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	values, found := res.values(app.store.catalog(), id)
+	err = app.store.update(sessionFrom(r).Username, func(c *catalog) error { return res.delete(c, id) })
+	var invalid validationError
+	if errors.As(err, &invalid) && found {
+		app.renderAdminForm(w, r, http.StatusConflict, kind, id, values, invalid)
+		return
+	}
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/admin#"+kind, http.StatusSeeOther)
+}
+
+func (app *App) renderAdminForm(w http.ResponseWriter, r *http.Request, status int, kind string, id int, values url.Values, errs validationError) {
+	res := adminResources[kind]
+	data := struct {
+		adminPage
+		Heading      string
+		Action       string
+		DeleteAction string
+		PhotoAction  string
+		Photo        string
+		PhotoError   string
+		History      string
+		Fields       []formField
+		Error        string
+	}{
+		adminPage:  app.adminPage(r, "Catalog admin - Aurora Cars"),
+		Action:     fmt.Sprintf("/admin/%s/new", kind),
+		PhotoError: errs["photo"],
+		Error:      errs[""],
+	}
+	if id == 0 {
+		data.Heading = "New " + res.Singular
+	} else {
+		data.Heading = fmt.Sprintf("Edit %s %s", res.Singular, values.Get("name"))
+		data.Action = fmt.Sprintf("/admin/%s/%d", kind, id)
+		data.DeleteAction = data.Action + "/delete"
+		data.History = fmt.Sprintf("/admin/audit?kind=%s&id=%d", res.Singular, id)
+		if kind == "cars" {
+			data.PhotoAction = data.Action + "/image"
+			data.Photo = values.Get("image")
+		}
+	}
+	for _, field := range res.fields(app.store.catalog()) {
+		field.Value = values.Get(field.Name)
+		field.Error = errs[field.Name]
+		data.Fields = append(data.Fields, field)
+	}
+	app.renderStatus(w, r, status, "admin_form.html", data)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupAdminApp(t *testing.T) (*App, string) {
+	t.Helper()
+	iterations := passwordIterations
+	passwordIterations = 1000
+	t.Cleanup(func() { passwordIterations = iterations })
+
+	app := setupCatalogApp(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	if err := writeCatalogFile(path, app.snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	app.config.Admin = AdminConfig{Enabled: true, DataFile: path, AccountsFile: filepath.Join(dir, "accounts.json"), AuditFile: filepath.Join(dir, "audit.jsonl")}
+	if err := app.enableAdmin(app.config.Admin); err != nil {
+		t.Fatal(err)
+	}
+	for name, r := range map[string]string{"vera": "viewer", "eddie": "editor", "ada": "admin"} {
+		acct, err := newAccount(name, r, "correct horse battery")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.accounts.put(acct); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return app, path
+}
+
+// requestAs sends a console request with username's session cookie. Forms
+// get the session's CSRF token unless they already carry one.
+func requestAs(app *App, username, method, target string, form url.Values) *httptest.ResponseRecorder {
+	var cookie *http.Cookie
+	if acct, ok := app.accounts.get(username); ok {
+		rec := httptest.NewRecorder()
+		app.setSession(rec, httptest.NewRequest("GET", "/admin/login", nil), acct)
+		cookie = rec.Result().Cookies()[0]
+	}
+
+	var req *http.Request
+	if method == http.MethodPost {
+		if form == nil {
+			form = url.Values{}
+		}
+		if _, ok := form["csrf"]; !ok && cookie != nil {
+			probe := httptest.NewRequest("GET", "/admin", nil)
+			probe.AddCookie(cookie)
+			s, _ := app.currentSession(probe)
+			form.Set("csrf", app.csrfToken(s))
+		}
+		req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	app.adminHandler(rr, req)
+	return rr
+}
+
+func adminRequest(app *App, method, target string, form url.Values) *httptest.ResponseRecorder {
+	return requestAs(app, "eddie", method, target, form)
+}
+
+func TestAdmin_RequiresSession(t *testing.T) {
+	app, _ := setupAdminApp(t)
+
+	rr := requestAs(app, "nobody", "GET", "/admin/cars/1", nil)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/login?next=%2Fadmin%2Fcars%2F1" {
+		t.Errorf("expected a redirect to the login form, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+	if rr := requestAs(app, "nobody", "POST", "/admin/cars/1/delete", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a post without a session, got %d", rr.Code)
+	}
+
+	if rr := adminRequest(app, "GET", "/admin", nil); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Toyota Corolla") {
+		t.Errorf("expected the console, got %d", rr.Code)
+	}
+
+	app.store = nil
+	if rr := adminRequest(app, "GET", "/admin", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("without a store the console should not exist, got %d", rr.Code)
+	}
+}
+
+func TestAdmin_CreateEditDelete(t *testing.T) {
+	app, path := setupAdminApp(t)
+
+	rr := adminRequest(app, "POST", "/admin/manufacturers/new", url.Values{"name": {"Honda"}, "country": {"Japan"}, "foundingYear": {"1948"}})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("create: expected 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if m := app.snapshot().Manufacturers; len(m) != 3 || m[2].ID != 3 || m[2].Name != "Honda" {
+		t.Fatalf("the snapshot should pick up the new manufacturer, got %+v", m)
+	}
+
+	rr = adminRequest(app, "POST", "/admin/cars/3", url.Values{
+		"name": {"Toyota Corolla"}, "manufacturerId": {"2"}, "categoryId": {"2"}, "year": {"2024"},
+		"engine": {"1.8L Inline-4"}, "horsepower": {"169"}, "transmission": {"CVT"}, "drivetrain": {"Front-Wheel Drive"},
+	})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("edit: expected 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	car, _ := app.snapshot().findCar(3)
+	if car == nil || car.Year != 2024 || car.Specifications.Horsepower != 169 || car.ManufacturerName != "Toyota" {
+		t.Errorf("the snapshot should pick up the edit, got %+v", car)
+	}
+
+	if rr := adminRequest(app, "POST", "/admin/cars/1/delete", nil); rr.Code != http.StatusSeeOther {
+		t.Fatalf("delete: expected 303, got %d", rr.Code)
+	}
+	if car, _ := app.snapshot().findCar(1); car != nil {
+		t.Errorf("the deleted car is still in the snapshot")
+	}
+
+	// The file on disk matches, in the Node API's format.
+	saved, err := loadCatalogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Manufacturers) != 3 || len(saved.CarModels) != 2 || saved.CarModels[1].Specifications.Horsepower != 169 {
+		t.Errorf("unexpected file contents %+v", saved)
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), "manufacturerName") {
+		t.Errorf("derived fields should not be written:\n%s", raw)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp")); len(leftovers) > 0 {
+		t.Errorf("temporary files were left behind: %v", leftovers)
+	}
+}
+
+func TestAdmin_IndexListsOrphanCars(t *testing.T) {
+	app, _ := setupAdminApp(t)
+	// A data file edited by hand can name a manufacturer that is gone.
+	app.store.data.CarModels[0].ManufacturerID = 99
+
+	rr := adminRequest(app, "GET", "/admin", nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Mercedes-Benz GLE") {
+		t.Errorf("expected the catalog listing with the orphan car, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAdmin_Validation(t *testing.T) {
+	app, path := setupAdminApp(t)
+	before, _ := os.ReadFile(path)
+
+	rr := adminRequest(app, "POST", "/admin/cars/new", url.Values{
+		"name": {""}, "manufacturerId": {"9"}, "categoryId": {"2"}, "year": {"1700"}, "horsepower": {"fast"}, "image": {"../secret.jpg"},
+	})
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		"Name is required.",
+		"Choose one of the listed manufacturers.",
+		"Year must be a whole number from 1886",
+		"Horsepower must be a whole number",
+		"Image file must be a plain file name.",
+		`<option value="2" selected>Sedan</option>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("form is missing %q", want)
+		}
+	}
+
+	rr = adminRequest(app, "POST", "/admin/categories/1", url.Values{"name": {"sedan"}})
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "Another category already has this name.") {
+		t.Errorf("duplicate names should be rejected, got %d", rr.Code)
+	}
+
+	rr = adminRequest(app, "POST", "/admin/manufacturers/2/delete", nil)
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "Toyota still has 1 model.") {
+		t.Errorf("a manufacturer with models should not be deleted, got %d", rr.Code)
+	}
+
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("rejected changes should leave the file alone")
+	}
+	if rr := adminRequest(app, "GET", "/admin/cars/99", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown record: expected 404, got %d", rr.Code)
+	}
+}
+
+func TestAdmin_Roles(t *testing.T) {
+	app, _ := setupAdminApp(t)
+
+	viewerForm := requestAs(app, "vera", "GET", "/admin/cars/1", nil)
+	if viewerForm.Code != http.StatusOK || !strings.Contains(viewerForm.Body.String(), "<fieldset disabled>") || strings.Contains(viewerForm.Body.String(), ">Save</button>") {
+		t.Errorf("viewers should get a read-only form, got %d", viewerForm.Code)
+	}
+	if rr := requestAs(app, "vera", "POST", "/admin/cars/1/delete", nil); rr.Code != http.StatusForbidden {
+		t.Errorf("viewers must not delete, got %d", rr.Code)
+	}
+	if rr := requestAs(app, "eddie", "GET", "/admin/users", nil); rr.Code != http.StatusForbidden {
+		t.Errorf("editors must not manage accounts, got %d", rr.Code)
+	}
+	if rr := requestAs(app, "ada", "GET", "/admin/users", nil); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "eddie") {
+		t.Errorf("admins should see the accounts, got %d", rr.Code)
+	}
+	if car, _ := app.snapshot().findCar(1); car == nil {
+		t.Errorf("the car should not have been deleted")
+	}
+}
+
+func TestAdmin_RequiresCSRFToken(t *testing.T) {
+	app, _ := setupAdminApp(t)
+	for _, token := range []string{"", "forged"} {
+		rr := adminRequest(app, "POST", "/admin/cars/1/delete", url.Values{"csrf": {token}})
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("token %q: expected 403, got %d", token, rr.Code)
+		}
+	}
+	if car, _ := app.snapshot().findCar(1); car == nil {
+		t.Errorf("the car should not have been deleted")
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"cars/structs"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// apiEnvelope wraps every /api/v1 response. Meta and Links are only set on
+// list endpoints.
+type apiEnvelope struct {
+	Data  interface{} `json:"data"`
+	Meta  *apiMeta    `json:"meta,omitempty"`
+	Links *apiLinks   `json:"links,omitempty"`
+}
+
+type apiMeta struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"perPage"`
+	Total      int `json:"total"`
+	TotalPages int `json:"totalPages"`
+}
+
+type apiLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// apiCar is a CarModel with its manufacturer and category optionally
+// embedded, as requested through ?embed=manufacturer,category.
+type apiCar struct {
+	structs.CarModel
+	Manufacturer *structs.Manufacturer `json:"manufacturer,omitempty"`
+	Category     *structs.Category     `json:"category,omitempty"`
+}
+
+type pagination struct {
+	Page    int
+	PerPage int
+}
+
+type apiErrorBody struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// apiEndpoint describes one /api/v1 route. The same table registers the
+// handlers and generates /openapi.json, so the two cannot drift apart.
+type apiEndpoint struct {
+	Path      string
+	Summary   string
+	Params    []apiParam
+	Response  reflect.Type
+	Paginated bool
+	Handler   http.HandlerFunc
+}
+
+type apiParam struct {
+	Name        string
+	In          string
+	Type        string
+	Description string
+	Repeated    bool
+}
+
+var (
+	filterParams = []apiParam{
+		{Name: "manufacturer", In: "query", Type: "integer", Description: "Only cars from this manufacturer ID."},
+		{Name: "category", In: "query", Type: "integer", Description: "Only cars in this category ID."},
+		{Name: "year", In: "query", Type: "integer", Description: "Only cars from this model year."},
+		{Name: "country", In: "query", Type: "string", Description: "Only cars whose manufacturer is based in this country."},
+		{Name: "query", In: "query", Type: "string", Description: "Case-insensitive search over name, year, manufacturer, category and country."},
+	}
+	paginationParams = []apiParam{
+		{Name: "page", In: "query", Type: "integer", Description: "Page number, starting at 1."},
+		{Name: "per_page", In: "query", Type: "integer", Description: fmt.Sprintf("Items per page, 1 to %d. Defaults to %d.", maxPerPage, defaultPerPage)},
+	}
+	embedParam = apiParam{Name: "embed", In: "query", Type: "string", Description: "Comma-separated related records to include: manufacturer, category."}
+)
+
+func (app *App) apiEndpoints() []apiEndpoint {
+	return []apiEndpoint{
+		{
+			Path:      "/api/v1/cars",
+			Summary:   "List cars, optionally filtered and searched",
+			Params:    append(append(append([]apiParam{}, filterParams...), paginationParams...), embedParam),
+			Response:  reflect.TypeOf([]apiCar{}),
+			Paginated: true,
+			Handler:   app.apiCarsHandler,
+		},
+		{
+			Path:     "/api/v1/cars/{id}",
+			Summary:  "Get one car",
+			Params:   []apiParam{{Name: "id", In: "path", Type: "integer", Description: "Car ID."}, embedParam},
+			Response: reflect.TypeOf(apiCar{}),
+			Handler:  app.apiCarHandler,
+		},
+		{
+			Path:      "/api/v1/manufacturers",
+			Summary:   "List manufacturers",
+			Params:    paginationParams,
+			Response:  reflect.TypeOf([]structs.Manufacturer{}),
+			Paginated: true,
+			Handler:   app.apiManufacturersHandler,
+		},
+		{
+			Path:      "/api/v1/categories",
+			Summary:   "List categories",
+			Params:    paginationParams,
+			Response:  reflect.TypeOf([]structs.Category{}),
+			Paginated: true,
+			Handler:   app.apiCategoriesHandler,
+		},
+		{
+			Path:    "/api/v1/compare",
+			Summary: "Get several cars side by side",
+			Params: []apiParam{
+				{Name: "ids", In: "query", Type: "string", Description: "Comma-separated car IDs."},
+				{Name: "car_ids", In: "query", Type: "integer", Description: "Car ID, repeatable like the HTML compare form.", Repeated: true},
+				embedParam,
+			},
+			Response: reflect.TypeOf([]apiCar{}),
+			Handler:  app.apiCompareHandler,
+		},
+	}
+}
+
+func (app *App) registerAPI(mux *http.ServeMux) {
+	for _, endpoint := range app.apiEndpoints() {
+		app.handleFunc(mux, endpoint.Path, endpoint.Handler)
+	}
+	app.handleFunc(mux, "/openapi.json", app.openAPIHandler)
+}
+
+// apiCarsHandler accepts the same manufacturer, category, year, country and
+// query parameters as the /filter and /search pages.
+func (app *App) apiCarsHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	page, err := parsePagination(r)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+	embed, err := parseEmbed(r)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+
+	c := app.snapshot()
+	cars := c.filterCars(carFilterFromRequest(r))
+	start, end, meta := page.window(len(cars))
+	app.writeJSON(w, http.StatusOK, apiEnvelope{
+		Data:  apiCars(c, cars[start:end], embed),
+		Meta:  meta,
+		Links: page.links(r, meta),
+	})
+}
+
+func (app *App) apiCarHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	embed, err := parseEmbed(r)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+
+	params, _ := matchRoute("/api/v1/cars/{id}", r.URL.Path)
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		app.renderError(w, r, errBadRequest(fmt.Sprintf("Invalid car ID %q. Car IDs are whole numbers.", params["id"])))
+		return
+	}
+	c := app.snapshot()
+	car, _ := c.findCar(id)
+	if car == nil {
+		app.renderError(w, r, errNotFound("Car not found."))
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, apiEnvelope{Data: apiCars(c, []structs.CarModel{*car}, embed)[0]})
+}
+
+func (app *App) apiManufacturersHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	page, err := parsePagination(r)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+
+	manufacturers := app.snapshot().Manufacturers
+	start, end, meta := page.window(len(manufacturers))
+	app.writeJSON(w, http.StatusOK, apiEnvelope{
+		// An empty catalog has nil slices, which would encode as null.
+		Data:  append([]structs.Manufacturer{}, manufacturers[start:end]...),
+		Meta:  meta,
+		Links: page.links(r, meta),
+	})
+}
+
+func (app *App) apiCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	page, err := parsePagination(r)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+
+	categories := app.snapshot().Categories
+	start, end, meta := page.window(len(categories))
+	app.writeJSON(w, http.StatusOK, apiEnvelope{
+		// An empty catalog has nil slices, which would encode as null.
+		Data:  append([]structs.Category{}, categories[start:end]...),
+		Meta:  meta,
+		Links: page.links(r, meta),
+	})
+}
+
+// apiCompareHandler takes the cars to compare either as repeated car_ids,
+// like the HTML form, or as a comma-separated ids list.
+func (app *App) apiCompareHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	embed, err := parseEmbed(r)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+
+	carIDs := r.URL.Query()["car_ids"]
+	if ids := r.URL.Query().Get("ids"); ids != "" {
+		carIDs = append(carIDs, strings.Split(ids, ",")...)
+	}
+	c := app.snapshot()
+	cars, err := c.carsToCompare(carIDs)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, apiEnvelope{Data: apiCars(c, cars, embed)})
+}
+
+func apiCars(c catalog, cars []structs.CarModel, embed map[string]bool) []apiCar {
+	result := make([]apiCar, len(cars))
+	for i, car := range cars {
+		result[i] = apiCar{CarModel: car}
+		if embed["manufacturer"] {
+			if _, m := c.findCar(car.ID); m != nil {
+				manufacturer := *m
+				result[i].Manufacturer = &manufacturer
+			}
+		}
+		if embed["category"] {
+			if found := c.findCategory(strconv.Itoa(car.CategoryID)); found != nil {
+				category := *found
+				result[i].Category = &category
+			}
+		}
+	}
+	return result
+}
+
+func (app *App) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func parseEmbed(r *http.Request) (map[string]bool, error) {
+	embed := make(map[string]bool)
+	for _, value := range strings.Split(r.URL.Query().Get("embed"), ",") {
+		value = strings.TrimSpace(value)
+		switch value {
+		case "":
+		case "manufacturer", "category":
+			embed[value] = true
+		default:
+			return nil, errBadRequest(fmt.Sprintf("Unknown embed %q. Use manufacturer and/or category.", value))
+		}
+	}
+	return embed, nil
+}
+
+func parsePagination(r *http.Request) (pagination, error) {
+	page := pagination{Page: 1, PerPage: defaultPerPage}
+	if v := r.URL.Query().Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return page, errBadRequest(fmt.Sprintf("Invalid page %q. Pages start at 1.", v))
+		}
+		page.Page = n
+	}
+	if v := r.URL.Query().Get("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPerPage {
+			return page, errBadRequest(fmt.Sprintf("Invalid per_page %q. Use a number from 1 to %d.", v, maxPerPage))
+		}
+		page.PerPage = n
+	}
+	return page, nil
+}
+
+// window returns the slice bounds of the current page within total items.
+// Pages past the end are valid and simply empty; they are recognised before
+// multiplying, as a huge page number would overflow the offset.
+func (p pagination) window(total int) (start, end int, meta *apiMeta) {
+	start = total
+	if p.Page-1 <= total/p.PerPage {
+		start = min((p.Page-1)*p.PerPage, total)
+	}
+	end = min(start+p.PerPage, total)
+	meta = &apiMeta{
+		Page:       p.Page,
+		PerPage:    p.PerPage,
+		Total:      total,
+		TotalPages: (total + p.PerPage - 1) / p.PerPage,
+	}
+	return start, end, meta
+}
+
+func (p pagination) links(r *http.Request, meta *apiMeta) *apiLinks {
+	pageURL := func(page int) string {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", strconv.Itoa(p.PerPage))
+		return (&url.URL{Path: r.URL.Path, RawQuery: q.Encode()}).String()
+	}
+
+	links := &apiLinks{Self: pageURL(p.Page)}
+	if p.Page < meta.TotalPages {
+		links.Next = pageURL(p.Page + 1)
+	}
+	if p.Page > 1 {
+		links.Prev = pageURL(min(p.Page-1, max(meta.TotalPages, 1)))
+	}
+	return links
+}
@@ -1,6 +1,9 @@
+const fs = require("fs");
+const path = require("path");
 const express = require("express");
 const expressStatic = require("express-static");
-const data = require("./data.json");
+
+const DATA_FILE = path.join(__dirname, "data.json");
 
 
 const app = express();
@@ -24,9 +27,34 @@ app.get("/api", (req, res) => {
 app.use("/api/images", expressStatic("img"));
 
 
-const carModels = data.carModels
-, categories = data.categories
-, manufacturers = data.manufacturers;
+let carModels = []
+, categories = []
+, manufacturers = [];
+
+// The Go admin console rewrites data.json by renaming a new file over it,
+// so poll for changes rather than holding on to the old file. A file that
+// fails to parse leaves the previous data in place.
+function loadData() {
+  try {
+    const data = JSON.parse(fs.readFileSync(DATA_FILE, "utf8"));
+    carModels = data.carModels;
+    categories = data.categories;
+    manufacturers = data.manufacturers;
+    return true;
+  } catch (err) {
+    console.error(`Failed to load ${DATA_FILE}: ${err.message}`);
+    return false;
+  }
+}
+
+if (!loadData()) {
+  process.exit(1);
+}
+fs.watchFile(DATA_FILE, { interval: 1000 }, (curr, prev) => {
+  if (curr.mtimeMs !== prev.mtimeMs && loadData()) {
+    console.log("Reloaded data.json");
+  }
+});
 
 
 // Car Models Handler
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testEnvelope struct {
+	Data  json.RawMessage `json:"data"`
+	Meta  *apiMeta        `json:"meta"`
+	Links *apiLinks       `json:"links"`
+}
+
+func getAPI(t *testing.T, app *App, handler http.HandlerFunc, url string, wantStatus int) testEnvelope {
+	t.Helper()
+	req, rr := httptest.NewRequest("GET", url, nil), httptest.NewRecorder()
+	handler(rr, req)
+
+	if rr.Code != wantStatus {
+		t.Fatalf("%s: expected status %d, got %d: %s", url, wantStatus, rr.Code, rr.Body.String())
+	}
+	var env testEnvelope
+	if err := json.Unmarshal(rr.Body.Bytes(), &env); err != nil {
+		t.Fatalf("%s: invalid JSON: %v", url, err)
+	}
+	return env
+}
+
+func TestAPICars_FilterSearchAndPaginate(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	env := getAPI(t, app, app.apiCarsHandler, "/api/v1/cars?country=Germany&per_page=1&page=2", http.StatusOK)
+	var cars []apiCar
+	if err := json.Unmarshal(env.Data, &cars); err != nil {
+		t.Fatal(err)
+	}
+	if len(cars) != 1 || cars[0].ID != 2 {
+		t.Errorf("expected second German car, got %+v", cars)
+	}
+	if env.Meta.Total != 2 || env.Meta.TotalPages != 2 || env.Meta.Page != 2 {
+		t.Errorf("unexpected meta: %+v", env.Meta)
+	}
+	if env.Links.Next != "" || env.Links.Prev == "" {
+		t.Errorf("unexpected links: %+v", env.Links)
+	}
+
+	env = getAPI(t, app, app.apiCarsHandler, "/api/v1/cars?query=corolla", http.StatusOK)
+	cars = nil
+	json.Unmarshal(env.Data, &cars)
+	if len(cars) != 1 || cars[0].ID != 3 {
+		t.Errorf("expected search to find the Corolla, got %+v", cars)
+	}
+}
+
+func TestAPICars_Embed(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	env := getAPI(t, app, app.apiCarHandler, "/api/v1/cars/1?embed=manufacturer,category", http.StatusOK)
+	var car apiCar
+	if err := json.Unmarshal(env.Data, &car); err != nil {
+		t.Fatal(err)
+	}
+	if car.Manufacturer == nil || car.Manufacturer.Name != "Mercedes-Benz" {
+		t.Errorf("expected embedded manufacturer, got %+v", car.Manufacturer)
+	}
+	if car.Category == nil || car.Category.Name != "SUV" {
+		t.Errorf("expected embedded category, got %+v", car.Category)
+	}
+
+	env = getAPI(t, app, app.apiCarHandler, "/api/v1/cars/1", http.StatusOK)
+	car = apiCar{}
+	json.Unmarshal(env.Data, &car)
+	if car.Manufacturer != nil || car.Category != nil {
+		t.Errorf("expected nothing embedded by default, got %+v", car)
+	}
+}
+
+func TestAPI_Errors(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	tests := []struct {
+		url     string
+		handler http.HandlerFunc
+		status  int
+	}{
+		{"/api/v1/cars/99", app.apiCarHandler, http.StatusNotFound},
+		{"/api/v1/cars/abc", app.apiCarHandler, http.StatusBadRequest},
+		{"/api/v1/cars?page=0", app.apiCarsHandler, http.StatusBadRequest},
+		{"/api/v1/cars?per_page=1000", app.apiCarsHandler, http.StatusBadRequest},
+		{"/api/v1/cars?embed=engine", app.apiCarsHandler, http.StatusBadRequest},
+		{"/api/v1/compare?ids=1,x", app.apiCompareHandler, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req, rr := httptest.NewRequest("GET", tt.url, nil), httptest.NewRecorder()
+		tt.handler(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.url, tt.status, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("%s: expected JSON error, got Content-Type %q", tt.url, ct)
+		}
+	}
+}
+
+func TestAPICompareAndLists(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	env := getAPI(t, app, app.apiCompareHandler, "/api/v1/compare?ids=1,3&embed=manufacturer", http.StatusOK)
+	var cars []apiCar
+	json.Unmarshal(env.Data, &cars)
+	if len(cars) != 2 || cars[1].Manufacturer == nil || cars[1].Manufacturer.Name != "Toyota" {
+		t.Errorf("unexpected comparison: %+v", cars)
+	}
+
+	env = getAPI(t, app, app.apiManufacturersHandler, "/api/v1/manufacturers", http.StatusOK)
+	if env.Meta.Total != 2 {
+		t.Errorf("expected 2 manufacturers, got %+v", env.Meta)
+	}
+	env = getAPI(t, app, app.apiCategoriesHandler, "/api/v1/categories?page=5", http.StatusOK)
+	if string(env.Data) != "[]" {
+		t.Errorf("expected empty page past the end, got %s", env.Data)
+	}
+	env = getAPI(t, app, app.apiCarsHandler, "/api/v1/cars?page=9223372036854775807&per_page=100", http.StatusOK)
+	if string(env.Data) != "[]" || env.Links.Next != "" {
+		t.Errorf("expected an empty last page for a huge page number, got %s %+v", env.Data, env.Links)
+	}
+}
+
+func TestAPILists_EmptyCatalog(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.setCatalog(catalog{LoadedAt: time.Now()})
+
+	for _, list := range []struct {
+		url     string
+		handler http.HandlerFunc
+	}{
+		{"/api/v1/cars", app.apiCarsHandler},
+		{"/api/v1/manufacturers", app.apiManufacturersHandler},
+		{"/api/v1/categories", app.apiCategoriesHandler},
+	} {
+		env := getAPI(t, app, list.handler, list.url, http.StatusOK)
+		if string(env.Data) != "[]" || env.Meta.Total != 0 {
+			t.Errorf("%s: expected an empty list, got %s %+v", list.url, env.Data, env.Meta)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// compressibleAssets are the extensions worth storing gzipped; images are
+// compressed already.
+var compressibleAssets = map[string]bool{
+	".css": true, ".js": true, ".svg": true, ".json": true, ".txt": true, ".html": true, ".xml": true,
+}
+
+// asset is one static file, read into memory at startup.
+type asset struct {
+	name        string
+	fingerprint string
+	contentType string
+	data        []byte
+	gzipped     []byte
+	etag        string
+	modTime     time.Time
+}
+
+// assetSet maps static files to fingerprinted names such as
+// styles.3f2a9c1d.css. The hash changes with the content, so pages always
+// link to the current version and browsers may keep each one for good.
+type assetSet struct {
+	byName        map[string]*asset
+	byFingerprint map[string]*asset
+}
+
+// loadAssets reads every file in fsys, fingerprints it and prepares a
+// gzipped copy of the text formats.
+func loadAssets(fsys fs.FS) (*assetSet, error) {
+	set := &assetSet{byName: make(map[string]*asset), byFingerprint: make(map[string]*asset)}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		a, err := newAsset(p, data, info.ModTime())
+		if err != nil {
+			return err
+		}
+		set.byName[a.name] = a
+		set.byFingerprint[a.fingerprint] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func newAsset(name string, data []byte, modTime time.Time) (*asset, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	ext := path.Ext(name)
+	a := &asset{
+		name:        name,
+		fingerprint: strings.TrimSuffix(name, ext) + "." + hash[:8] + ext,
+		contentType: mime.TypeByExtension(ext),
+		data:        data,
+		etag:        `"` + hash[:32] + `"`,
+		modTime:     modTime,
+	}
+	if a.contentType == "" {
+		a.contentType = http.DetectContentType(data)
+	}
+	if compressibleAssets[ext] {
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		if buf.Len() < len(data) {
+			a.gzipped = buf.Bytes()
+		}
+	}
+	return a, nil
+}
+
+// url returns the fingerprinted URL of a static file. Names the set does
+// not know fall back to their plain URL. The nil set, used when templates
+// are parsed without assets, always does.
+func (s *assetSet) url(name string) string {
+	if s != nil {
+		if a, ok := s.byName[name]; ok {
+			return "/static/" + a.fingerprint
+		}
+	}
+	return "/static/" + name
+}
+
+// staticHandler serves the files in the asset set. Fingerprinted names are
+// immutable; plain names still work for links the templates don't make, but
+// must be revalidated.
+func (app *App) staticHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/static/")
+	a, ok := app.assets.byFingerprint[name]
+	if ok {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else if a, ok = app.assets.byName[name]; ok {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		app.notFoundHandler(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", a.contentType)
+	body, etag := a.data, a.etag
+	if a.gzipped != nil {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if acceptsGzip(r) {
+			w.Header().Set("Content-Encoding", "gzip")
+			body, etag = a.gzipped, strings.TrimSuffix(a.etag, `"`)+`-gz"`
+		}
+	}
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, a.name, a.modTime, bytes.NewReader(body))
+}
+
+// serveAsset writes a static file by its plain name, for the few URLs like
+// /favicon.png that browsers request on their own.
+func (app *App) serveAsset(w http.ResponseWriter, r *http.Request, name string) {
+	a, ok := app.assets.byName[name]
+	if !ok {
+		app.notFoundHandler(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", a.contentType)
+	w.Header().Set("ETag", a.etag)
+	http.ServeContent(w, r, a.name, a.modTime, bytes.NewReader(a.data))
+}
+
+// acceptsGzip reports whether the client accepts gzip, honouring an
+// explicit q=0.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
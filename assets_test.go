@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func setupAssetApp(t *testing.T) *App {
+	t.Helper()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "styles.css"), []byte(strings.Repeat("body { color: #333; }\n", 50)), 0o644)
+	os.Mkdir(filepath.Join(dir, "js"), 0o755)
+	os.WriteFile(filepath.Join(dir, "js", "app.js"), []byte(strings.Repeat("console.log('hi');\n", 50)), 0o644)
+	os.WriteFile(filepath.Join(dir, "logo.png"), []byte("\x89PNG\r\n\x1a\nnot much"), 0o644)
+
+	assets, err := loadAssets(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := parseTemplates(bundledFS("templates", ""), assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &App{templates: tmpl, assets: assets}
+}
+
+func getStatic(app *App, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rr := httptest.NewRecorder()
+	app.staticHandler(rr, req)
+	return rr
+}
+
+func TestAssets_Fingerprints(t *testing.T) {
+	app := setupAssetApp(t)
+
+	css := app.assets.url("styles.css")
+	if !regexp.MustCompile(`^/static/styles\.[0-9a-f]{8}\.css$`).MatchString(css) {
+		t.Errorf("unexpected fingerprinted URL %s", css)
+	}
+	if js := app.assets.url("js/app.js"); !regexp.MustCompile(`^/static/js/app\.[0-9a-f]{8}\.js$`).MatchString(js) {
+		t.Errorf("files in subdirectories keep their directory, got %s", js)
+	}
+	if got := app.assets.url("missing.css"); got != "/static/missing.css" {
+		t.Errorf("unknown assets should keep their plain URL, got %s", got)
+	}
+
+	var page bytes.Buffer
+	if err := app.templates.ExecuteTemplate(&page, "error.html", map[string]any{"Status": 404}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.String(), `href="`+css+`"`) {
+		t.Errorf("pages should link the fingerprinted stylesheet:\n%s", page.String())
+	}
+}
+
+func TestAssets_Caching(t *testing.T) {
+	app := setupAssetApp(t)
+	css := app.assets.url("styles.css")
+
+	rr := getStatic(app, css, nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Fatalf("fingerprinted assets should be immutable, got %d %v", rr.Code, rr.Header())
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/css") || rr.Header().Get("Content-Encoding") != "" {
+		t.Errorf("unexpected headers without gzip support %v", rr.Header())
+	}
+	etag := rr.Header().Get("ETag")
+	if rr := getStatic(app, css, http.Header{"If-None-Match": {etag}}); rr.Code != http.StatusNotModified {
+		t.Errorf("a matching ETag should give 304, got %d", rr.Code)
+	}
+
+	if rr := getStatic(app, "/static/styles.css", nil); rr.Code != http.StatusOK || rr.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("plain names should be served but revalidated, got %d %q", rr.Code, rr.Header().Get("Cache-Control"))
+	}
+	if rr := getStatic(app, "/static/styles.00000000.css", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("stale fingerprints should be 404, got %d", rr.Code)
+	}
+}
+
+func TestAssets_Gzip(t *testing.T) {
+	app := setupAssetApp(t)
+	css := app.assets.url("styles.css")
+
+	rr := getStatic(app, css, http.Header{"Accept-Encoding": {"br, gzip;q=0.8"}})
+	if rr.Header().Get("Content-Encoding") != "gzip" || rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected a gzipped response, got %v", rr.Header())
+	}
+	if !strings.HasSuffix(rr.Header().Get("ETag"), `-gz"`) {
+		t.Errorf("the gzipped variant needs its own ETag, got %s", rr.Header().Get("ETag"))
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := io.ReadAll(zr)
+	if !bytes.Equal(plain, app.assets.byName["styles.css"].data) {
+		t.Errorf("the gzipped body should decode to the stylesheet")
+	}
+
+	if rr := getStatic(app, css, http.Header{"Accept-Encoding": {"gzip;q=0"}}); rr.Header().Get("Content-Encoding") != "" {
+		t.Errorf("q=0 refuses gzip")
+	}
+	if rr := getStatic(app, app.assets.url("logo.png"), http.Header{"Accept-Encoding": {"gzip"}}); rr.Header().Get("Content-Encoding") != "" || rr.Header().Get("Vary") != "" {
+		t.Errorf("images should not be gzipped, got %v", rr.Header())
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditPageSize is how many entries the audit page shows; the JSON export
+// has them all.
+const auditPageSize = 200
+
+// auditEntry records one created, updated or deleted catalog record, one
+// per line of the audit log.
+type auditEntry struct {
+	Time    time.Time     `json:"time"`
+	Actor   string        `json:"actor"`
+	Action  string        `json:"action"`
+	Kind    string        `json:"kind"`
+	ID      int           `json:"id"`
+	Name    string        `json:"name"`
+	Changes []fieldChange `json:"changes"`
+}
+
+func (e auditEntry) When() string {
+	return e.Time.UTC().Format("2006-01-02 15:04:05 UTC")
+}
+
+// auditEntries turns the difference a change made into log entries.
+func auditEntries(at time.Time, actor string, d catalogDiff) []auditEntry {
+	var entries []auditEntry
+	for _, section := range []struct {
+		action  string
+		changes []recordChange
+	}{{"created", d.Added}, {"updated", d.Changed}, {"deleted", d.Removed}} {
+		for _, change := range section.changes {
+			entries = append(entries, auditEntry{
+				Time:    at.UTC(),
+				Actor:   actor,
+				Action:  section.action,
+				Kind:    change.Kind,
+				ID:      change.ID,
+				Name:    change.Name,
+				Changes: change.Fields,
+			})
+		}
+	}
+	return entries
+}
+
+// auditLog is an append-only file of JSON lines. Entries are only ever
+// added to its end, so it can be shipped or rotated with ordinary tools.
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (l *auditLog) append(entries []auditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var buf strings.Builder
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(buf.String()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// read returns the entries that match, oldest first. A log that does not
+// exist yet has no entries.
+func (l *auditLog) read(match func(auditEntry) bool) ([]auditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", l.path, line, err)
+		}
+		if match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// auditFilter narrows the log by the query parameters the audit page's
+// form sends. Dates are whole days in the server's time zone.
+type auditFilter struct {
+	Kind  string
+	ID    string
+	Actor string
+	From  string
+	To    string
+
+	id       int
+	from, to time.Time
+}
+
+func parseAuditFilter(query url.Values) (auditFilter, error) {
+	f := auditFilter{
+		Kind:  strings.TrimSpace(query.Get("kind")),
+		ID:    strings.TrimSpace(query.Get("id")),
+		Actor: strings.TrimSpace(query.Get("actor")),
+		From:  strings.TrimSpace(query.Get("from")),
+		To:    strings.TrimSpace(query.Get("to")),
+	}
+	if f.Kind != "" && !contains(auditKinds(), f.Kind) {
+		return f, errBadRequest(fmt.Sprintf("Kind must be one of %s.", strings.Join(auditKinds(), ", ")))
+	}
+	if f.ID != "" {
+		id, err := strconv.Atoi(f.ID)
+		if err != nil || id < 1 {
+			return f, errBadRequest("ID must be a positive whole number.")
+		}
+		f.id = id
+	}
+	for _, date := range []struct {
+		label string
+		value string
+		into  *time.Time
+	}{{"From", f.From, &f.from}, {"To", f.To, &f.to}} {
+		if date.value == "" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", date.value, time.Local)
+		if err != nil {
+			return f, errBadRequest(fmt.Sprintf("%s must be a date like 2024-01-31.", date.label))
+		}
+		*date.into = t
+	}
+	if !f.to.IsZero() {
+		f.to = f.to.AddDate(0, 0, 1)
+	}
+	return f, nil
+}
+
+func (f auditFilter) match(e auditEntry) bool {
+	return (f.Kind == "" || e.Kind == f.Kind) &&
+		(f.id == 0 || e.ID == f.id) &&
+		(f.Actor == "" || strings.EqualFold(e.Actor, f.Actor)) &&
+		(f.from.IsZero() || !e.Time.Before(f.from)) &&
+		(f.to.IsZero() || e.Time.Before(f.to))
+}
+
+// Query is the filter as a query string, for the export link.
+func (f auditFilter) Query() string {
+	q := url.Values{}
+	for name, value := range map[string]string{"kind": f.Kind, "id": f.ID, "actor": f.Actor, "from": f.From, "to": f.To} {
+		if value != "" {
+			q.Set(name, value)
+		}
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
+}
+
+func auditKinds() []string {
+	return []string{"car", "manufacturer", "category"}
+}
+
+// auditHandler serves the audit log at /admin/audit and, filtered the same
+// way, as a JSON download at /admin/audit.json.
+func (app *App) auditHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+	entries, err := app.store.audit.read(filter.match)
+	if err != nil {
+		app.renderError(w, r, errInternal(err))
+		return
+	}
+
+	if r.URL.Path == "/admin/audit.json" {
+		if entries == nil {
+			entries = []auditEntry{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.json"`)
+		if r.Method == http.MethodHead {
+			return
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(entries)
+		return
+	}
+
+	// Newest first, and only the latest page of them.
+	total := len(entries)
+	shown := make([]auditEntry, 0, min(total, auditPageSize))
+	for i := total - 1; i >= 0 && len(shown) < auditPageSize; i-- {
+		shown = append(shown, entries[i])
+	}
+	data := struct {
+		adminPage
+		Filter  auditFilter
+		Kinds   []string
+		Entries []auditEntry
+		Total   int
+	}{
+		adminPage: app.adminPage(r, "Audit log - Aurora Cars"),
+		Filter:    filter,
+		Kinds:     auditKinds(),
+		Entries:   shown,
+		Total:     total,
+	}
+	app.render(w, r, "admin_audit.html", data)
+}
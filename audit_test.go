@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAudit_RecordsChanges(t *testing.T) {
+	app, _ := setupAdminApp(t)
+
+	form := url.Values{"name": {"Mercedes-Benz GLE"}, "manufacturerId": {"1"}, "categoryId": {"1"}, "year": {"2022"}, "horsepower": {"375"}}
+	if rr := adminRequest(app, "POST", "/admin/cars/1", form); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected the edit to be saved, got %d", rr.Code)
+	}
+	if rr := requestAs(app, "ada", "POST", "/admin/cars/2/delete", nil); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected the delete to succeed, got %d", rr.Code)
+	}
+	// A save that changes nothing leaves nothing to record.
+	adminRequest(app, "POST", "/admin/cars/1", form)
+
+	entries, err := app.store.audit.read(func(auditEntry) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected two entries, got %+v", entries)
+	}
+	edit, del := entries[0], entries[1]
+	if edit.Actor != "eddie" || edit.Action != "updated" || edit.Kind != "car" || edit.ID != 1 ||
+		len(edit.Changes) != 1 || edit.Changes[0] != (fieldChange{Field: "horsepower", Label: "Horsepower", Old: "362", New: "375"}) {
+		t.Errorf("unexpected edit entry %+v", edit)
+	}
+	if time.Since(edit.Time) > time.Minute {
+		t.Errorf("the entry should carry the time of the change, got %v", edit.Time)
+	}
+	if del.Actor != "ada" || del.Action != "deleted" || del.Name != "Mercedes-Benz E-Class 2023" {
+		t.Errorf("unexpected delete entry %+v", del)
+	}
+	var hadHorsepower bool
+	for _, change := range del.Changes {
+		hadHorsepower = hadHorsepower || (change.Field == "horsepower" && change.Old == "255" && change.New == "")
+	}
+	if !hadHorsepower {
+		t.Errorf("a deleted record's values should be kept, got %+v", del.Changes)
+	}
+}
+
+func TestAudit_UnwritableLogBlocksChanges(t *testing.T) {
+	app, path := setupAdminApp(t)
+	before, _ := os.ReadFile(path)
+	app.store.audit.path = t.TempDir() // a directory cannot be appended to
+
+	rr := requestAs(app, "eddie", "POST", "/admin/cars/2/delete", nil)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected the change to fail, got %d", rr.Code)
+	}
+	if after, _ := os.ReadFile(path); string(before) != string(after) {
+		t.Errorf("a change that could not be audited must not be written")
+	}
+	if car, _ := app.snapshot().findCar(2); car == nil {
+		t.Errorf("a change that could not be audited must not be published")
+	}
+}
+
+func TestAudit_FailedWriteIsNotRecorded(t *testing.T) {
+	app, _ := setupAdminApp(t)
+	app.store.path = filepath.Join(t.TempDir(), "missing", "data.json")
+
+	rr := requestAs(app, "eddie", "POST", "/admin/cars/2/delete", nil)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected the change to fail, got %d", rr.Code)
+	}
+	entries, err := app.store.audit.read(func(auditEntry) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("a change that was not written must not be audited, got %+v", entries)
+	}
+	if car, _ := app.snapshot().findCar(2); car == nil {
+		t.Errorf("a change that was not written must not be published")
+	}
+}
+
+func TestAuditHandler(t *testing.T) {
+	app, _ := setupAdminApp(t)
+	adminRequest(app, "POST", "/admin/categories/new", url.Values{"name": {"Coupe"}})
+	adminRequest(app, "POST", "/admin/cars/3/delete", nil)
+
+	rr := requestAs(app, "vera", "GET", "/admin/audit", nil)
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, "2 changes.") {
+		t.Fatalf("viewers should see the log, got %d:\n%s", rr.Code, body)
+	}
+	if strings.Index(body, "car 3") > strings.Index(body, "category 3") {
+		t.Errorf("the newest change should come first")
+	}
+
+	rr = requestAs(app, "vera", "GET", "/admin/audit?kind=category&actor=EDDIE", nil)
+	body = rr.Body.String()
+	if !strings.Contains(body, "1 changes.") || !strings.Contains(body, "<ins>Coupe</ins>") || strings.Contains(body, "Toyota Corolla") {
+		t.Errorf("the filters should narrow the log:\n%s", body)
+	}
+	today := time.Now().Format("2006-01-02")
+	if rr := requestAs(app, "vera", "GET", "/admin/audit?to="+today, nil); !strings.Contains(rr.Body.String(), "2 changes.") {
+		t.Errorf("the end date should include the whole day")
+	}
+	if rr := requestAs(app, "vera", "GET", "/admin/audit?from=2999-01-01", nil); !strings.Contains(rr.Body.String(), "0 changes.") {
+		t.Errorf("a later start date should leave nothing")
+	}
+	if rr := requestAs(app, "vera", "GET", "/admin/audit?from=yesterday", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("bad dates should be refused, got %d", rr.Code)
+	}
+
+	rr = requestAs(app, "vera", "GET", "/admin/audit.json?kind=car&id=3", nil)
+	var entries []auditEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("export is not JSON: %v\n%s", err, rr.Body.String())
+	}
+	if len(entries) != 1 || entries[0].Action != "deleted" || entries[0].Actor != "eddie" {
+		t.Errorf("unexpected export %+v", entries)
+	}
+	if got := rr.Header().Get("Content-Disposition"); !strings.Contains(got, "audit.json") {
+		t.Errorf("the export should download, got %q", got)
+	}
+	if rr := requestAs(app, "", "GET", "/admin/audit.json", nil); rr.Code == http.StatusOK {
+		t.Errorf("the log must not be public")
+	}
+}
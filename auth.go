@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// role orders what a console user may do; each role can do everything the
+// ones below it can.
+type role int
+
+const (
+	roleViewer role = iota + 1
+	roleEditor
+	roleAdmin
+)
+
+var roleNames = map[role]string{roleViewer: "viewer", roleEditor: "editor", roleAdmin: "admin"}
+
+func (r role) String() string {
+	return roleNames[r]
+}
+
+func parseRole(name string) (role, bool) {
+	for r, n := range roleNames {
+		if n == name {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// Passwords are stored as PBKDF2-HMAC-SHA256 hashes with a random salt, in
+// the form "pbkdf2-sha256$<iterations>$<salt>$<key>". The iteration count is
+// stored with each hash so it can be raised without invalidating old ones.
+const (
+	passwordScheme  = "pbkdf2-sha256"
+	passwordSaltLen = 16
+	passwordKeyLen  = 32
+)
+
+// passwordIterations follows the OWASP recommendation for PBKDF2-SHA256.
+// Tests lower it to keep hashing fast.
+var passwordIterations = 600000
+
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2([]byte(password), salt, passwordIterations, passwordKeyLen, sha256.New)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func verifyPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err1 := base64.RawStdEncoding.DecodeString(parts[2])
+	want, err2 := base64.RawStdEncoding.DecodeString(parts[3])
+	if err1 != nil || err2 != nil || len(want) == 0 {
+		return false
+	}
+	got := pbkdf2([]byte(password), salt, iterations, len(want), sha256.New)
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// pbkdf2 derives a key as described in RFC 8018, section 5.2.
+func pbkdf2(password, salt []byte, iterations, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	size := prf.Size()
+	blocks := (keyLen + size - 1) / size
+
+	key := make([]byte, 0, blocks*size)
+	u := make([]byte, size)
+	t := make([]byte, size)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u = prf.Sum(u[:0])
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// dummyPasswordHash is checked against when a login names an unknown
+// account, so the response takes as long as for a real one.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := hashPassword("not a real password")
+	return hash
+})
+
+const (
+	sessionCookie = "aurora_admin"
+	sessionTTL    = 12 * time.Hour
+)
+
+// session is a signed-in console user. It lives entirely in a cookie signed
+// with the session key. The cookie also carries a stamp derived from the
+// account's password hash, so changing the password ends every session.
+type session struct {
+	Username string
+	Role     role
+	nonce    string
+}
+
+type sessionContextKey struct{}
+
+// sessionFrom returns the session requireRole attached to the request.
+func sessionFrom(r *http.Request) *session {
+	s, _ := r.Context().Value(sessionContextKey{}).(*session)
+	return s
+}
+
+func passwordStamp(passwordHash string) string {
+	sum := sha256.Sum256([]byte(passwordHash))
+	return base64.RawURLEncoding.EncodeToString(sum[:8])
+}
+
+func (app *App) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, app.sessionKey)
+	mac.Write([]byte(strings.Join(parts, "|")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// setSession signs acct in by setting a fresh session cookie.
+func (app *App) setSession(w http.ResponseWriter, r *http.Request, acct account) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	expires := time.Now().Add(sessionTTL)
+	payload := strings.Join([]string{acct.Username, strconv.FormatInt(expires.Unix(), 10),
+		base64.RawURLEncoding.EncodeToString(nonce), passwordStamp(acct.PasswordHash)}, "|")
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + app.sign("session", payload),
+		Path:     "/admin",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   app.isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func (app *App) clearSession(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/admin", MaxAge: -1, HttpOnly: true, Secure: app.isHTTPS(r), SameSite: http.SameSiteLaxMode})
+}
+
+// currentSession checks the session cookie's signature and expiry and that
+// its account still exists with the same password. The role is always read
+// from the account, so a role change applies at once.
+func (app *App) currentSession(r *http.Request) (*session, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, false
+	}
+	encoded, mac, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return nil, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !hmac.Equal([]byte(mac), []byte(app.sign("session", string(raw)))) {
+		return nil, false
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 4 {
+		return nil, false
+	}
+	expiresUnix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() >= expiresUnix {
+		return nil, false
+	}
+	acct, ok := app.accounts.get(parts[0])
+	if !ok || passwordStamp(acct.PasswordHash) != parts[3] {
+		return nil, false
+	}
+	return &session{Username: acct.Username, Role: acct.Role, nonce: parts[2]}, true
+}
+
+// csrfToken is the token the session's forms must send back. It is tied to
+// the session, so a token from another session or user is worthless.
+func (app *App) csrfToken(s *session) string {
+	if s == nil {
+		return ""
+	}
+	return app.sign("csrf", s.Username, s.nonce)
+}
+
+// isHTTPS reports whether the client reached this site over HTTPS. The
+// X-Forwarded-Proto header is only believed from the proxy trustProxy says
+// is in front of the server; anyone else could send it.
+func (app *App) isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || (app.config.TrustProxy && r.Header.Get("X-Forwarded-Proto") == "https")
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// requireRole lets a request through to next only for a signed-in user with
+// at least minRole. Page loads without a session are sent to the login form.
+// Every unsafe request must also carry the session's CSRF token.
+func (app *App) requireRole(minRole role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := app.currentSession(r)
+		if !ok {
+			if isSafeMethod(r.Method) && !wantsJSON(r) {
+				http.Redirect(w, r, "/admin/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+			app.renderError(w, r, errUnauthorized())
+			return
+		}
+		if s.Role < minRole {
+			app.renderError(w, r, errForbidden(fmt.Sprintf("This needs the %s role; you are signed in as %s.", minRole, s.Role)))
+			return
+		}
+		if !isSafeMethod(r.Method) {
+			if err := parseAdminForm(r); err != nil {
+				app.renderError(w, r, err)
+				return
+			}
+			if !hmac.Equal([]byte(r.PostForm.Get("csrf")), []byte(app.csrfToken(s))) {
+				app.renderError(w, r, errForbidden("This form has expired. Go back, reload the page and try again."))
+				return
+			}
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, s)))
+	}
+}
+
+// parseAdminForm reads a console form, including file uploads, whose size
+// adminHandler has already capped.
+func parseAdminForm(r *http.Request) error {
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err = r.ParseMultipartForm(maxUploadBytes)
+	} else {
+		err = r.ParseForm()
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errTooLarge(fmt.Sprintf("Uploads may be at most %d MB.", maxUploadBytes>>20))
+	}
+	if err != nil {
+		return errBadRequest("The form could not be read.")
+	}
+	return nil
+}
+
+// Accounts are locked for a while after maxLoginFailures wrong passwords in
+// a row. Each further failure doubles the lockout, up to maxLoginLockout.
+const (
+	maxLoginFailures = 5
+	loginLockout     = time.Minute
+	maxLoginLockout  = time.Hour
+)
+
+// maxPasswordChecks caps the password hashes computed at once, whatever the
+// account name, so a flood of logins cannot take every CPU.
+const maxPasswordChecks = 4
+
+type loginAttempts struct {
+	failures    int
+	lockedUntil time.Time
+}
+
+// loginLimiter tracks failed logins per account name. Names that do not
+// exist are tracked too, so probing for accounts looks the same as guessing
+// a password.
+type loginLimiter struct {
+	mu       sync.Mutex
+	attempts map[string]*loginAttempts
+	checking int
+}
+
+// startCheck reserves one of the maxPasswordChecks slots, reporting false
+// when all are taken. A reserved slot is given back with endCheck.
+func (l *loginLimiter) startCheck() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.checking >= maxPasswordChecks {
+		return false
+	}
+	l.checking++
+	return true
+}
+
+func (l *loginLimiter) endCheck() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checking--
+}
+
+// wait returns how long username must wait before it may try again.
+func (l *loginLimiter) wait(username string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if a := l.attempts[username]; a != nil && now.Before(a.lockedUntil) {
+		return a.lockedUntil.Sub(now)
+	}
+	return 0
+}
+
+func (l *loginLimiter) fail(username string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.attempts == nil {
+		l.attempts = make(map[string]*loginAttempts)
+	}
+	// Forget unlocked names once the map grows, so random names cannot
+	// exhaust memory.
+	if len(l.attempts) > 10000 {
+		for name, a := range l.attempts {
+			if now.After(a.lockedUntil) {
+				delete(l.attempts, name)
+			}
+		}
+	}
+	a := l.attempts[username]
+	if a == nil {
+		a = &loginAttempts{}
+		l.attempts[username] = a
+	}
+	a.failures++
+	if a.failures >= maxLoginFailures {
+		lockout := loginLockout << min(a.failures-maxLoginFailures, 6)
+		a.lockedUntil = now.Add(min(lockout, maxLoginLockout))
+	}
+}
+
+func (l *loginLimiter) succeed(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, username)
+}
+
+// safeRedirect keeps the post-login redirect inside the console.
+func safeRedirect(next string) string {
+	if next == "/admin" || (strings.HasPrefix(next, "/admin/") && !strings.HasPrefix(next, "/admin/log")) {
+		return next
+	}
+	return "/admin"
+}
+
+func (app *App) loginHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	data := struct {
+		adminPage
+		Username string
+		Next     string
+		Error    string
+	}{
+		adminPage: adminPage{Title: "Sign in - Aurora Cars"},
+		Next:      safeRedirect(r.FormValue("next")),
+	}
+	if r.Method == http.MethodGet {
+		app.render(w, r, "admin_login.html", data)
+		return
+	}
+
+	// Login forms have no session to tie a token to, so check where the
+	// post came from instead.
+	if !sameOrigin(r) {
+		app.renderError(w, r, errForbidden("This form was sent from another site."))
+		return
+	}
+	username := strings.ToLower(strings.TrimSpace(r.PostFormValue("username")))
+	password := r.PostFormValue("password")
+	data.Username = username
+
+	if !app.logins.startCheck() {
+		w.Header().Set("Retry-After", "1")
+		data.Error = "Too many sign-ins at once. Try again in a moment."
+		app.renderStatus(w, r, http.StatusServiceUnavailable, "admin_login.html", data)
+		return
+	}
+	defer app.logins.endCheck()
+
+	now := time.Now()
+	if wait := app.logins.wait(username, now); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		data.Error = fmt.Sprintf("Too many failed attempts. Try again in %s.", wait.Round(time.Second))
+		app.renderStatus(w, r, http.StatusTooManyRequests, "admin_login.html", data)
+		return
+	}
+
+	acct, found := app.accounts.get(username)
+	if !found {
+		verifyPassword(dummyPasswordHash(), password)
+	}
+	if !found || !verifyPassword(acct.PasswordHash, password) {
+		app.logins.fail(username, now)
+		data.Error = "Wrong username or password."
+		app.renderStatus(w, r, http.StatusUnauthorized, "admin_login.html", data)
+		return
+	}
+
+	app.logins.succeed(username)
+	if err := app.setSession(w, r, acct); err != nil {
+		app.renderError(w, r, errInternal(err))
+		return
+	}
+	http.Redirect(w, r, data.Next, http.StatusSeeOther)
+}
+
+func (app *App) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	app.clearSession(w, r)
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+var errNoSessionKey = errors.New("admin.sessionKey must be at least 32 bytes of base64")
+
+// decodeSessionKey reads the configured session key. An empty key yields a
+// random one, which signs everyone out whenever the server restarts.
+func decodeSessionKey(encoded string) ([]byte, error) {
+	if encoded == "" {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		return key, err
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) < 32 {
+		return nil, errNoSessionKey
+	}
+	return key, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPBKDF2_RFC7914Vector(t *testing.T) {
+	got := hex.EncodeToString(pbkdf2([]byte("passwd"), []byte("salt"), 1, 64, sha256.New))
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestPasswordHashing(t *testing.T) {
+	passwordIterations = 1000
+	defer func() { passwordIterations = 600000 }()
+
+	first, err := hashPassword("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := hashPassword("correct horse battery")
+	if first == second {
+		t.Errorf("hashes of the same password should differ by salt")
+	}
+	if !strings.HasPrefix(first, "pbkdf2-sha256$1000$") {
+		t.Errorf("unexpected hash format %s", first)
+	}
+	if !verifyPassword(first, "correct horse battery") || verifyPassword(first, "correct horse staple") {
+		t.Errorf("verifyPassword accepted the wrong password or rejected the right one")
+	}
+	if verifyPassword("plain-text", "plain-text") {
+		t.Errorf("malformed hashes must never verify")
+	}
+}
+
+func login(app *App, username, password string) *httptest.ResponseRecorder {
+	form := url.Values{"username": {username}, "password": {password}, "next": {"/admin/cars/1"}}
+	req := httptest.NewRequest("POST", "/admin/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	app.adminHandler(rr, req)
+	return rr
+}
+
+func withCookies(rr *httptest.ResponseRecorder, target string) *http.Request {
+	req := httptest.NewRequest("GET", target, nil)
+	for _, c := range rr.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	return req
+}
+
+func TestLogin_SessionLifecycle(t *testing.T) {
+	app, _ := setupAdminApp(t)
+
+	rr := login(app, "Eddie", "correct horse battery")
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/cars/1" {
+		t.Fatalf("expected a redirect back to the console, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+	cookie := rr.Result().Cookies()[0]
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/admin" {
+		t.Errorf("unexpected cookie attributes %+v", cookie)
+	}
+	s, ok := app.currentSession(withCookies(rr, "/admin"))
+	if !ok || s.Username != "eddie" || s.Role != roleEditor {
+		t.Fatalf("the new cookie should hold a valid session, got %+v", s)
+	}
+
+	tampered := httptest.NewRequest("GET", "/admin", nil)
+	tampered.AddCookie(&http.Cookie{Name: sessionCookie, Value: strings.Replace(cookie.Value, ".", "x.", 1)})
+	if _, ok := app.currentSession(tampered); ok {
+		t.Errorf("a tampered cookie must be rejected")
+	}
+
+	// Role changes apply to existing sessions; password changes end them.
+	acct, _ := app.accounts.get("eddie")
+	if err := app.updateAccount(acct, "viewer", ""); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := app.currentSession(withCookies(rr, "/admin")); !ok || s.Role != roleViewer {
+		t.Errorf("expected the session to pick up the new role, got %+v", s)
+	}
+	acct, _ = app.accounts.get("eddie")
+	if err := app.updateAccount(acct, "viewer", "a whole new password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := app.currentSession(withCookies(rr, "/admin")); ok {
+		t.Errorf("a password change should end existing sessions")
+	}
+
+	if rr := login(app, "eddie", "wrong password!"); rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "Wrong username or password.") {
+		t.Errorf("expected 401 for a wrong password, got %d", rr.Code)
+	}
+	if rr := login(app, "ghost", "correct horse battery"); rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "Wrong username or password.") {
+		t.Errorf("unknown accounts should look like wrong passwords, got %d", rr.Code)
+	}
+}
+
+func TestLogin_Throttling(t *testing.T) {
+	app, _ := setupAdminApp(t)
+	for i := 0; i < maxLoginFailures; i++ {
+		login(app, "ada", "guess number "+string(rune('a'+i)))
+	}
+	rr := login(app, "ada", "correct horse battery")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("a locked account should be refused even with the right password, got %d", rr.Code)
+	}
+	if rr := login(app, "eddie", "correct horse battery"); rr.Code != http.StatusSeeOther {
+		t.Errorf("other accounts should not be locked, got %d", rr.Code)
+	}
+
+	// Password checks are capped whichever account they are for.
+	app.logins.checking = maxPasswordChecks
+	if rr := login(app, "nobody", "guess"); rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("logins past the hashing cap should be refused, got %d", rr.Code)
+	}
+	app.logins.checking--
+	if rr := login(app, "eddie", "correct horse battery"); rr.Code != http.StatusSeeOther || app.logins.checking != maxPasswordChecks-1 {
+		t.Errorf("a free slot should allow a login and be given back, got %d with %d in use", rr.Code, app.logins.checking)
+	}
+
+	var l loginLimiter
+	now := time.Now()
+	for i := 0; i < maxLoginFailures+1; i++ {
+		l.fail("bob", now)
+	}
+	if wait := l.wait("bob", now); wait != 2*loginLockout {
+		t.Errorf("each failure past the limit should double the lockout, got %s", wait)
+	}
+	if l.wait("bob", now.Add(2*loginLockout)) != 0 {
+		t.Errorf("the lockout should expire")
+	}
+	l.succeed("bob")
+	l.fail("bob", now)
+	if l.wait("bob", now) != 0 {
+		t.Errorf("a successful login should reset the count")
+	}
+}
+
+func TestLogout(t *testing.T) {
+	app, _ := setupAdminApp(t)
+	rr := requestAs(app, "eddie", "POST", "/admin/logout", nil)
+	if rr.Code != http.StatusSeeOther || rr.Result().Cookies()[0].MaxAge >= 0 {
+		t.Errorf("expected the session cookie to be cleared, got %d", rr.Code)
+	}
+}
+
+func TestAccounts_KeepsAnAdmin(t *testing.T) {
+	app, _ := setupAdminApp(t)
+
+	rr := requestAs(app, "ada", "POST", "/admin/users/ada", url.Values{"role": {"editor"}})
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "At least one account must keep the admin role.") {
+		t.Errorf("demoting the last admin should fail, got %d", rr.Code)
+	}
+	if rr := requestAs(app, "ada", "POST", "/admin/users/ada/delete", nil); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("deleting the last admin should fail, got %d", rr.Code)
+	}
+
+	rr = requestAs(app, "ada", "POST", "/admin/users/new", url.Values{"username": {"Vera"}, "role": {"admin"}, "password": {"short"}})
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "already an account") {
+		t.Errorf("duplicate usernames should be rejected, got %d", rr.Code)
+	}
+	rr = requestAs(app, "ada", "POST", "/admin/users/new", url.Values{"username": {"zoe"}, "role": {"admin"}, "password": {"short"}})
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "Password must be 10 to 256 characters.") {
+		t.Errorf("short passwords should be rejected, got %d", rr.Code)
+	}
+	if rr := requestAs(app, "ada", "POST", "/admin/users/new", url.Values{"username": {"zoe"}, "role": {"admin"}, "password": {"long enough now"}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected the account to be created, got %d", rr.Code)
+	}
+	if rr := requestAs(app, "ada", "POST", "/admin/users/ada/delete", nil); rr.Code != http.StatusSeeOther {
+		t.Errorf("with a second admin the first may go, got %d", rr.Code)
+	}
+}
+
+func TestRunUser(t *testing.T) {
+	passwordIterations = 1000
+	defer func() { passwordIterations = 600000 }()
+	path := filepath.Join(t.TempDir(), "accounts.json")
+
+	var out bytes.Buffer
+	if err := runUser([]string{"add", "-accounts", path, "-role", "admin", "Ada"}, strings.NewReader("correct horse battery\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := runUser([]string{"add", "-accounts", path, "bob"}, strings.NewReader("short\n"), &out); err == nil {
+		t.Errorf("a short password should be refused")
+	}
+
+	out.Reset()
+	if err := runUser([]string{"list", "-accounts", path}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out.String()) != "ada  admin" {
+		t.Errorf("unexpected listing %q", out.String())
+	}
+
+	accounts, _ := openAccounts(path)
+	acct, ok := accounts.get("ada")
+	if !ok || !verifyPassword(acct.PasswordHash, "correct horse battery") {
+		t.Errorf("the account should have been saved with its password")
+	}
+}
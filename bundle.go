@@ -0,0 +1,55 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// bundle holds the templates, static files and stock car photos, so the
+// binary runs from any working directory.
+//
+//go:embed templates static api/img
+var bundle embed.FS
+
+// bundledFS returns the embedded copy of dir, or the on-disk directory
+// override when one is given, which lets templates and styles be edited
+// without rebuilding.
+func bundledFS(dir, override string) fs.FS {
+	if override != "" {
+		return os.DirFS(override)
+	}
+	sub, err := fs.Sub(bundle, dir)
+	if err != nil {
+		panic(err) // dir is one of the embedded directories
+	}
+	return sub
+}
+
+// openImage opens a car photo. Photos in imageDir, such as uploads, come
+// first; the stock photos built into the binary fill in for the rest.
+func openImage(name string) (fs.File, error) {
+	if name == "" || name != filepath.Base(name) || name[0] == '.' {
+		return nil, fs.ErrNotExist
+	}
+	f, err := os.Open(filepath.Join(imageDir, name))
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return bundledFS("api/img", "").Open(name)
+}
+
+func imageExists(name string) bool {
+	f, err := openImage(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBundle_Overrides(t *testing.T) {
+	if _, err := fs.Stat(bundledFS("templates", ""), "layout.html"); err != nil {
+		t.Errorf("templates should be built in: %v", err)
+	}
+	if _, err := fs.Stat(bundledFS("static", ""), "styles.css"); err != nil {
+		t.Errorf("static files should be built in: %v", err)
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "only-here.html"), []byte("hi"), 0o644)
+	if _, err := fs.Stat(bundledFS("templates", dir), "only-here.html"); err != nil {
+		t.Errorf("an override should read from disk: %v", err)
+	}
+}
+
+func TestOpenImage_PrefersImageDir(t *testing.T) {
+	saved := imageDir
+	imageDir = t.TempDir()
+	t.Cleanup(func() { imageDir = saved })
+
+	if !imageExists("audi_a4.jpg") {
+		t.Fatalf("stock photos should come from the binary")
+	}
+	os.WriteFile(filepath.Join(imageDir, "audi_a4.jpg"), []byte("replaced"), 0o644)
+	f, err := openImage("audi_a4.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if data, _ := io.ReadAll(f); string(data) != "replaced" {
+		t.Errorf("photos in imageDir should win over built-in ones")
+	}
+
+	for _, name := range []string{"", "../go.mod", ".hidden", "missing.jpg"} {
+		if imageExists(name) {
+			t.Errorf("%q should not be found", name)
+		}
+	}
+}
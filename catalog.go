@@ -0,0 +1,126 @@
+package main
+
+import (
+	"cars/structs"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+)
+
+// catalog is one consistent load of the car data. loadData builds a new one
+// and swaps it in whole, so code holding a snapshot never sees a catalog
+// that is half old and half new.
+type catalog struct {
+	Manufacturers []structs.Manufacturer `json:"manufacturers"`
+	CarModels     []structs.CarModel     `json:"carModels"`
+	Categories    []structs.Category     `json:"categories"`
+	LoadedAt      time.Time              `json:"-"`
+	// Version counts the loads that changed what this server shows; caches
+	// derived from the catalog key on it.
+	Version int `json:"-"`
+}
+
+// snapshot returns the current catalog. The slices are replaced, never
+// modified, when new data arrives, so the snapshot stays valid for as long as
+// the caller needs it.
+func (app *App) snapshot() catalog {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	return app.catalog
+}
+
+// arrival records a model that appeared between two catalog loads.
+type arrival struct {
+	Car     structs.CarModel
+	AddedAt time.Time
+}
+
+// maxArrivals bounds the new-models feed.
+const maxArrivals = 50
+
+// setCatalog makes c the catalog this server shows, keeping only its
+// published records.
+func (app *App) setCatalog(c catalog) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.applyCatalog(c)
+}
+
+// applyCatalog does the work of setCatalog with app.mu held.
+func (app *App) applyCatalog(c catalog) {
+	now := time.Now()
+	full := c
+	c = c.public(now)
+	// The first load has nothing to compare against, so it announces nothing.
+	if !app.catalog.LoadedAt.IsZero() {
+		app.arrivals = addArrivals(app.arrivals, app.catalog.CarModels, c.CarModels, c.LoadedAt)
+	}
+	c.setManufacturerNames()
+	if !app.catalog.LoadedAt.IsZero() && app.catalog.sameContent(c) {
+		// Every visit to / reloads the data; when nothing changed, the
+		// catalog and the caches keyed on its version stay as they were.
+		c.LoadedAt, c.Version = app.catalog.LoadedAt, app.catalog.Version
+	} else {
+		c.Version = app.catalog.Version + 1
+	}
+	app.catalog = c
+	app.schedulePublishing(full, now)
+}
+
+// sameContent reports whether c and other hold the same records.
+func (c catalog) sameContent(other catalog) bool {
+	return reflect.DeepEqual(c.Manufacturers, other.Manufacturers) &&
+		reflect.DeepEqual(c.CarModels, other.CarModels) &&
+		reflect.DeepEqual(c.Categories, other.Categories)
+}
+
+func (app *App) recentArrivals() []arrival {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	return app.arrivals
+}
+
+// addArrivals prepends the models in next that were not in prev, newest
+// first, keeping at most maxArrivals. It returns a new slice so earlier
+// results handed out by recentArrivals are left untouched.
+func addArrivals(arrivals []arrival, prev, next []structs.CarModel, at time.Time) []arrival {
+	known := make(map[int]bool, len(prev))
+	for _, car := range prev {
+		known[car.ID] = true
+	}
+	var added []arrival
+	for i := len(next) - 1; i >= 0; i-- {
+		if !known[next[i].ID] {
+			added = append(added, arrival{Car: next[i], AddedAt: at})
+		}
+	}
+	if len(added) == 0 {
+		return arrivals
+	}
+	result := append(added, arrivals...)
+	if len(result) > maxArrivals {
+		result = result[:maxArrivals]
+	}
+	return result
+}
+
+// loadCatalogFile reads a catalog in the format of the Node API's data.json,
+// for working without the API server.
+func loadCatalogFile(path string) (catalog, error) {
+	var c catalog
+	f, err := os.Open(path)
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&c); err != nil {
+		return c, fmt.Errorf("failed to decode catalog %s: %w", path, err)
+	}
+	if info, err := f.Stat(); err == nil {
+		c.LoadedAt = info.ModTime()
+	}
+	return c, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type Config struct {
+	APIURL  string `json:"apiUrl"`
+	SiteURL string `json:"siteUrl"`
+	// AllowedHosts lists the hosts, with their port unless it is the
+	// scheme's default, whose requests may supply the site's absolute URLs
+	// while SiteURL is empty. Requests for any other host cannot.
+	AllowedHosts []string `json:"allowedHosts"`
+	// TrustProxy says the server runs behind a reverse proxy that sets
+	// X-Forwarded-Proto, which is otherwise ignored.
+	TrustProxy      bool                 `json:"trustProxy"`
+	Recommendations RecommendationConfig `json:"recommendations"`
+	GraphQL         GraphQLConfig        `json:"graphql"`
+	Embed           EmbedConfig          `json:"embed"`
+	Admin           AdminConfig          `json:"admin"`
+}
+
+type RecommendationConfig struct {
+	Count   int               `json:"count"`
+	Weights SimilarityWeights `json:"weights"`
+}
+
+type GraphQLConfig struct {
+	// MaxDepth is the deepest field nesting a query may use; 0 disables the
+	// limit.
+	MaxDepth int `json:"maxDepth"`
+	// MaxComplexity is the most fields a query may select, counting a
+	// fragment again wherever it is spread; 0 disables the limit.
+	MaxComplexity int `json:"maxComplexity"`
+}
+
+type EmbedConfig struct {
+	// AllowedOrigins lists the partner sites, such as
+	// "https://partner.example", that may frame /embed/ pages. The site
+	// itself always may.
+	AllowedOrigins []string `json:"allowedOrigins"`
+}
+
+type AdminConfig struct {
+	Enabled bool `json:"enabled"`
+	// DataFile is the catalog the admin console edits. While the console
+	// is enabled it is also where this server reads the catalog from.
+	DataFile     string `json:"dataFile"`
+	AccountsFile string `json:"accountsFile"`
+	// AuditFile is the append-only log of every catalog change, one JSON
+	// object per line.
+	AuditFile string `json:"auditFile"`
+	// SessionKey signs session cookies and CSRF tokens: at least 32 random
+	// bytes, base64 encoded. When empty a new key is made at startup, which
+	// signs everyone out on every restart.
+	SessionKey string `json:"sessionKey"`
+}
+
+type SimilarityWeights struct {
+	Category   float64 `json:"category"`
+	Horsepower float64 `json:"horsepower"`
+	Year       float64 `json:"year"`
+	Drivetrain float64 `json:"drivetrain"`
+	Country    float64 `json:"country"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		APIURL:       "http://localhost:3000",
+		AllowedHosts: []string{"localhost:8080", "127.0.0.1:8080"},
+		Recommendations: RecommendationConfig{
+			Count: 4,
+			Weights: SimilarityWeights{
+				Category:   3,
+				Horsepower: 2,
+				Year:       1,
+				Drivetrain: 1,
+				Country:    1,
+			},
+		},
+		GraphQL: GraphQLConfig{MaxDepth: 8, MaxComplexity: 500},
+		Admin:   AdminConfig{DataFile: "api/data.json", AccountsFile: "admin_accounts.json", AuditFile: "admin_audit.jsonl"},
+	}
+}
+
+// loadConfig reads the JSON config at path on top of the defaults. A missing
+// file is not an error, so the server runs out of the box without one.
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		log.Printf("Config file %s not found, using defaults", path)
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("failed to decode config %s: %w", path, err)
+	}
+	if cfg.Recommendations.Count < 0 {
+		return cfg, fmt.Errorf("recommendations.count must not be negative, got %d", cfg.Recommendations.Count)
+	}
+	if cfg.GraphQL.MaxDepth < 0 {
+		return cfg, fmt.Errorf("graphql.maxDepth must not be negative, got %d", cfg.GraphQL.MaxDepth)
+	}
+	if cfg.GraphQL.MaxComplexity < 0 {
+		return cfg, fmt.Errorf("graphql.maxComplexity must not be negative, got %d", cfg.GraphQL.MaxComplexity)
+	}
+	if cfg.Admin.Enabled && (cfg.Admin.DataFile == "" || cfg.Admin.AccountsFile == "" || cfg.Admin.AuditFile == "") {
+		return cfg, fmt.Errorf("admin.dataFile, admin.accountsFile and admin.auditFile are required when the admin console is enabled")
+	}
+	if _, err := decodeSessionKey(cfg.Admin.SessionKey); err != nil {
+		return cfg, err
+	}
+	for _, origin := range cfg.Embed.AllowedOrigins {
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" {
+			return cfg, fmt.Errorf("embed.allowedOrigins: %q is not an origin like https://partner.example", origin)
+		}
+	}
+	return cfg, nil
+}
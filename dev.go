@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// devTemplates keeps the templates in sync with their directory on disk
+// during development. A change that no longer parses leaves the last good
+// set in place, and its error is shown over every page until it is fixed.
+type devTemplates struct {
+	dir    string
+	assets *assetSet
+
+	mu    sync.RWMutex
+	tmpl  *template.Template
+	err   error
+	stamp string
+}
+
+func newDevTemplates(dir string, assets *assetSet) (*devTemplates, error) {
+	d := &devTemplates{dir: dir, assets: assets}
+	d.reload()
+	if d.tmpl == nil {
+		return nil, d.err
+	}
+	return d, nil
+}
+
+// watch polls the directory every interval. Polling needs nothing beyond
+// the standard library and is cheap for a directory of a few dozen files.
+func (d *devTemplates) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		d.reload()
+	}
+}
+
+// reload reparses the templates if any file changed since the last look.
+func (d *devTemplates) reload() {
+	stamp, err := dirStamp(d.dir)
+	if err != nil {
+		log.Printf("Failed to check templates in %s: %v", d.dir, err)
+		return
+	}
+	d.mu.RLock()
+	unchanged := stamp == d.stamp
+	d.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	tmpl, err := parseTemplates(os.DirFS(d.dir), d.assets)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stamp = stamp
+	d.err = err
+	if err != nil {
+		log.Printf("Template error, keeping the previous templates: %v", err)
+		return
+	}
+	if d.tmpl != nil {
+		log.Printf("Reloaded templates from %s", d.dir)
+	}
+	d.tmpl = tmpl
+}
+
+func (d *devTemplates) current() (*template.Template, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.tmpl, d.err
+}
+
+// dirStamp summarises the names, sizes and modification times of the
+// templates in dir; any edit, addition or removal changes it.
+func dirStamp(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".html" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, fmt.Sprintf("%s %d %d", e.Name(), info.Size(), info.ModTime().UnixNano()))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\n"), nil
+}
+
+// currentTemplates returns the templates to render with and, in
+// development, the error from the latest reload, if any.
+func (app *App) currentTemplates() (*template.Template, error) {
+	if app.dev != nil {
+		return app.dev.current()
+	}
+	return app.templates, nil
+}
+
+var devOverlayTemplate = template.Must(template.New("overlay").Parse(`
+<div id="dev-template-error" style="position:fixed;inset:0;z-index:2147483647;overflow:auto;background:rgba(20,24,28,.94);color:#f7f9fc;font:14px/1.5 system-ui,sans-serif;padding:32px">
+<h1 style="margin:0 0 8px;color:#ff6b6b;font-size:20px">{{.Heading}}</h1>
+<pre style="white-space:pre-wrap;background:#0d1013;padding:16px;border-radius:6px;font:13px/1.5 ui-monospace,monospace">{{.Error}}</pre>
+<p style="color:#cfd6dd">{{.Hint}}</p>
+</div>
+`))
+
+// devOverlay renders err as a full-window panel for development pages.
+func devOverlay(heading string, err error, hint string) []byte {
+	var buf bytes.Buffer
+	devOverlayTemplate.Execute(&buf, map[string]string{"Heading": heading, "Error": err.Error(), "Hint": hint})
+	return buf.Bytes()
+}
+
+// withDevOverlay puts the reload error over a rendered page, just before
+// </body>, so the page that was being worked on stays visible underneath.
+func withDevOverlay(page []byte, err error) []byte {
+	overlay := devOverlay("Template error", err, "The page below uses the last templates that parsed. Save a fix and reload.")
+	i := bytes.LastIndex(page, []byte("</body>"))
+	if i < 0 {
+		return append(page, overlay...)
+	}
+	out := make([]byte, 0, len(page)+len(overlay))
+	out = append(out, page[:i]...)
+	out = append(out, overlay...)
+	return append(out, page[i:]...)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupDevApp copies the templates to a temporary directory and serves them
+// in development mode.
+func setupDevApp(t *testing.T) (*App, string) {
+	t.Helper()
+	dir := t.TempDir()
+	templates := bundledFS("templates", "")
+	names, _ := fs.Glob(templates, "*.html")
+	for _, name := range names {
+		data, _ := fs.ReadFile(templates, name)
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := setupCatalogApp(t)
+	dev, err := newDevTemplates(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.dev = dev
+	return app, dir
+}
+
+// editTemplate rewrites a template with a modification time that is sure
+// to differ from the last one seen.
+func editTemplate(t *testing.T, dir, name string, edit func(string) string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, []byte(edit(string(data))), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+}
+
+func renderNotFound(app *App) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	app.renderError(rr, httptest.NewRequest("GET", "/nowhere", nil), errNotFound("Nothing here."))
+	return rr
+}
+
+func TestDevTemplates_Reload(t *testing.T) {
+	app, dir := setupDevApp(t)
+
+	editTemplate(t, dir, "error.html", func(s string) string {
+		return strings.Replace(s, "Back to all cars", "Back to the showroom", 1)
+	})
+	app.dev.reload()
+	if rr := renderNotFound(app); !strings.Contains(rr.Body.String(), "Back to the showroom") {
+		t.Fatalf("the edited template should be used:\n%s", rr.Body.String())
+	}
+
+	editTemplate(t, dir, "error.html", func(s string) string {
+		return strings.Replace(s, "{{.Message}}", "{{.Message", 1)
+	})
+	app.dev.reload()
+	rr := renderNotFound(app)
+	body := rr.Body.String()
+	if rr.Code != http.StatusNotFound || !strings.Contains(body, "Back to the showroom") {
+		t.Errorf("a broken template should leave the last good one in use, got %d", rr.Code)
+	}
+	if !strings.Contains(body, `id="dev-template-error"`) || !strings.Contains(body, "error.html:") {
+		t.Errorf("the parse error should be shown over the page:\n%s", body)
+	}
+	if strings.Index(body, "dev-template-error") > strings.LastIndex(body, "</body>") {
+		t.Errorf("the overlay belongs inside the body")
+	}
+
+	editTemplate(t, dir, "error.html", func(s string) string {
+		return strings.Replace(s, "{{.Message", "{{.Message}}", 1)
+	})
+	app.dev.reload()
+	if rr := renderNotFound(app); strings.Contains(rr.Body.String(), "dev-template-error") {
+		t.Errorf("the overlay should go once the template is fixed")
+	}
+}
+
+func TestDevTemplates_ExecutionError(t *testing.T) {
+	app, dir := setupDevApp(t)
+	os.WriteFile(filepath.Join(dir, "broken.html"), []byte(`{{define "broken.html"}}{{.Missing.Field}}{{end}}`), 0o644)
+	app.dev.reload()
+
+	rr := httptest.NewRecorder()
+	app.render(rr, httptest.NewRequest("GET", "/", nil), "broken.html", struct{}{})
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "can&#39;t evaluate field Missing") {
+		t.Errorf("rendering errors should be shown in full during development, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+}
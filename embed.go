@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The card is responsive, so these are only the size oEmbed consumers are
+// told to reserve for the iframe.
+const (
+	embedWidth  = 400
+	embedHeight = 480
+)
+
+// oEmbedResponse is a "rich" oEmbed 1.0 response; see https://oembed.com.
+type oEmbedResponse struct {
+	Type            string `json:"type"`
+	Version         string `json:"version"`
+	Title           string `json:"title"`
+	ProviderName    string `json:"provider_name"`
+	ProviderURL     string `json:"provider_url"`
+	HTML            string `json:"html"`
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+	ThumbnailURL    string `json:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty"`
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty"`
+}
+
+// embedHandler serves /embed/car/{id}, a self-contained card partners can
+// put in an iframe. Only the site itself and the configured partner origins
+// may frame it.
+func (app *App) embedHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	params, _ := matchRoute("/embed/car/{id}", r.URL.Path)
+	carID, err := strconv.Atoi(params["id"])
+	if err != nil {
+		app.renderError(w, r, errBadRequest(fmt.Sprintf("Invalid car ID %q. Car IDs are whole numbers.", params["id"])))
+		return
+	}
+
+	data, err := app.carDetails(app.linkBase(r), carID)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self'; style-src 'unsafe-inline'; frame-ancestors "+app.frameAncestors())
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	app.respond(w, r, "embed.html", data)
+}
+
+func (app *App) frameAncestors() string {
+	sources := []string{"'self'"}
+	for _, origin := range app.config.Embed.AllowedOrigins {
+		sources = append(sources, strings.TrimSuffix(origin, "/"))
+	}
+	return strings.Join(sources, " ")
+}
+
+// oEmbedHandler answers /oembed?url=... for car page and embed URLs so CMSs
+// can turn a pasted link into the embedded card. Only JSON is offered.
+func (app *App) oEmbedHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "json" {
+		app.renderError(w, r, errNotImplemented(fmt.Sprintf("Format %q is not supported; use json.", format)))
+		return
+	}
+	if query.Get("url") == "" {
+		app.renderError(w, r, errBadRequest("The url parameter is required."))
+		return
+	}
+
+	// Zero means the consumer set no limit.
+	var maxWidth, maxHeight int
+	for name, limit := range map[string]*int{"maxwidth": &maxWidth, "maxheight": &maxHeight} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				app.renderError(w, r, errBadRequest(fmt.Sprintf("Invalid %s %q. It must be a positive whole number.", name, v)))
+				return
+			}
+			*limit = n
+		}
+	}
+	fits := func(width, height int) bool {
+		return (maxWidth == 0 || width <= maxWidth) && (maxHeight == 0 || height <= maxHeight)
+	}
+	width, height := embedWidth, embedHeight
+	if maxWidth > 0 {
+		width = min(width, maxWidth)
+	}
+	if maxHeight > 0 {
+		height = min(height, maxHeight)
+	}
+
+	base, err := app.baseURL(r)
+	if err != nil {
+		// Consumers need absolute links, so without a trusted origin they
+		// follow the URL asked about when it names this request's host. That
+		// URL is part of the cache key, unlike the Host header.
+		if u, err := url.Parse(query.Get("url")); err == nil && (u.Scheme == "http" || u.Scheme == "https") && strings.EqualFold(u.Host, r.Host) {
+			base = u.Scheme + "://" + u.Host
+		}
+	}
+	carID, ok := carIDFromURL(base, query.Get("url"))
+	if !ok {
+		app.renderError(w, r, errNotFound("No embed is available for that URL."))
+		return
+	}
+	data, err := app.carDetails(base, carID)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+
+	resp := oEmbedResponse{
+		Type:         "rich",
+		Version:      "1.0",
+		Title:        data.Meta.Title,
+		ProviderName: "Aurora cars",
+		ProviderURL:  base + "/",
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" title="%s" style="border:0" loading="lazy"></iframe>`,
+			template.HTMLEscapeString(fmt.Sprintf("%s/embed/car/%d", base, carID)), width, height, template.HTMLEscapeString(data.Meta.Title)),
+		Width:  width,
+		Height: height,
+	}
+	// The share image only comes in one size, so it is left out when the
+	// consumer asked for something smaller.
+	if data.Meta.Image != "" && fits(data.Meta.ImageWidth, data.Meta.ImageHeight) {
+		resp.ThumbnailURL = data.Meta.Image
+		resp.ThumbnailWidth = data.Meta.ImageWidth
+		resp.ThumbnailHeight = data.Meta.ImageHeight
+	}
+	app.writeJSON(w, http.StatusOK, resp)
+}
+
+// carIDFromURL extracts the car from one of this site's car page or embed
+// URLs.
+func carIDFromURL(base, rawURL string) (int, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, false
+	}
+	if site, err := url.Parse(base); err != nil || !strings.EqualFold(u.Host, site.Host) {
+		return 0, false
+	}
+	for _, pattern := range []string{"/cars/{id}", "/cars/{id}/{slug}", "/embed/car/{id}"} {
+		if params, ok := matchRoute(pattern, u.Path); ok {
+			id, err := strconv.Atoi(params["id"])
+			return id, err == nil
+		}
+	}
+	return 0, false
+}
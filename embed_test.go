@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmbed_CardAndFrameAncestors(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.config.Embed.AllowedOrigins = []string{"https://partner.example/", "https://dealer.example:8443"}
+
+	rr := get(t, app.embedHandler, "http://cars.example/embed/car/3")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	csp := rr.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "frame-ancestors 'self' https://partner.example https://dealer.example:8443") {
+		t.Errorf("unexpected CSP %q", csp)
+	}
+	if rr.Header().Get("X-Frame-Options") != "" {
+		t.Errorf("X-Frame-Options would block the allowed partners")
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		"<h1>Toyota Corolla</h1>",
+		"2023 &middot; Toyota, Japan",
+		`<a href="http://cars.example/cars/3/toyota-corolla-2023" target="_blank" rel="noopener">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("embed is missing %s", want)
+		}
+	}
+	if strings.Contains(body, "<link rel=\"stylesheet\"") || strings.Contains(body, "<script") {
+		t.Errorf("embed should not load other resources:\n%s", body)
+	}
+
+	if rr := get(t, app.embedHandler, "/embed/car/99"); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown car: expected 404, got %d", rr.Code)
+	}
+}
+
+func getOEmbed(t *testing.T, app *App, carURL string, extra string) (*httptest.ResponseRecorder, oEmbedResponse) {
+	t.Helper()
+	rr := get(t, app.oEmbedHandler, "http://cars.example/oembed?url="+url.QueryEscape(carURL)+extra)
+	var resp oEmbedResponse
+	if rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rr, resp
+}
+
+func TestOEmbed_CarURLs(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	for _, carURL := range []string{
+		"http://cars.example/cars/3/toyota-corolla-2023",
+		"http://cars.example/cars/3",
+		"http://cars.example/embed/car/3",
+	} {
+		rr, resp := getOEmbed(t, app, carURL, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", carURL, rr.Code)
+		}
+		if resp.Type != "rich" || resp.Version != "1.0" || resp.Title != "Toyota Corolla (2023)" {
+			t.Errorf("%s: unexpected response %+v", carURL, resp)
+		}
+		if !strings.Contains(resp.HTML, `<iframe src="http://cars.example/embed/car/3" width="400" height="480"`) {
+			t.Errorf("%s: unexpected html %s", carURL, resp.HTML)
+		}
+		if resp.ThumbnailURL != "http://cars.example/og/car/3.png" {
+			t.Errorf("%s: unexpected thumbnail %q", carURL, resp.ThumbnailURL)
+		}
+	}
+
+	_, small := getOEmbed(t, app, "http://cars.example/cars/3", "&maxwidth=300")
+	if small.Width != 300 || small.Height != 480 || small.ThumbnailURL != "" {
+		t.Errorf("maxwidth should shrink the frame and drop the large thumbnail, got %+v", small)
+	}
+}
+
+func TestOEmbed_Errors(t *testing.T) {
+	app := setupCatalogApp(t)
+	tests := []struct {
+		url, extra string
+		status     int
+	}{
+		{"http://elsewhere.example/cars/3", "", http.StatusNotFound},
+		{"http://cars.example/manufacturers/toyota", "", http.StatusNotFound},
+		{"http://cars.example/cars/99", "", http.StatusNotFound},
+		{"http://cars.example/cars/3", "&format=xml", http.StatusNotImplemented},
+		{"http://cars.example/cars/3", "&maxheight=tall", http.StatusBadRequest},
+		{"", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rr, _ := getOEmbed(t, app, tt.url, tt.extra)
+		if rr.Code != tt.status {
+			t.Errorf("%s%s: expected %d, got %d", tt.url, tt.extra, tt.status, rr.Code)
+		}
+		if !strings.HasPrefix(rr.Header().Get("Content-Type"), "application/json") {
+			t.Errorf("%s%s: errors should be JSON, got %q", tt.url, tt.extra, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestCarPage_OEmbedDiscovery(t *testing.T) {
+	app := setupCatalogApp(t)
+	body := get(t, app.CarDetailsHandler, "http://cars.example/cars/3/toyota-corolla-2023").Body.String()
+	want := `<link rel="alternate" type="application/json+oembed" href="http://cars.example/oembed?format=json&amp;url=http%3A%2F%2Fcars.example%2Fcars%2F3%2Ftoyota-corolla-2023"`
+	if !strings.Contains(body, want) {
+		t.Errorf("car page is missing the oEmbed discovery link:\n%s", body)
+	}
+}
+
+func TestLoadConfig_RejectsBadEmbedOrigins(t *testing.T) {
+	for _, origin := range []string{"partner.example", "https://partner.example/page", "ftp://partner.example"} {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(`{"embed": {"allowedOrigins": ["`+origin+`"]}}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig(path); err == nil {
+			t.Errorf("%s: expected an error", origin)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// HTTPError is an error that knows which status code it should be reported
+// with. Handlers return these through renderError instead of calling
+// http.Error directly so every failure looks the same to the client.
+type HTTPError struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+func errBadRequest(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusBadRequest, Message: message}
+}
+
+func errUnauthorized() *HTTPError {
+	return &HTTPError{Status: http.StatusUnauthorized, Message: "Please sign in to see this page."}
+}
+
+func errForbidden(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusForbidden, Message: message}
+}
+
+func errNotFound(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusNotFound, Message: message}
+}
+
+func errMethodNotAllowed() *HTTPError {
+	return &HTTPError{Status: http.StatusMethodNotAllowed, Message: "This method is not allowed for the requested page."}
+}
+
+func errNotImplemented(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusNotImplemented, Message: message}
+}
+
+func errTooLarge(message string) *HTTPError {
+	return &HTTPError{Status: http.StatusRequestEntityTooLarge, Message: message}
+}
+
+func errInternal(err error) *HTTPError {
+	return &HTTPError{Status: http.StatusInternalServerError, Message: "We're sorry, but something went wrong. Please try again later.", Err: err}
+}
+
+func errUnavailable(message string, err error) *HTTPError {
+	return &HTTPError{Status: http.StatusServiceUnavailable, Message: message, Err: err}
+}
+
+func errorHeading(status int) string {
+	if status == http.StatusNotFound {
+		return "Page Not Found"
+	}
+	return http.StatusText(status)
+}
+
+func (app *App) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		httpErr = errInternal(err)
+	}
+	if httpErr.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, httpErr)
+	}
+	if httpErr.Status == http.StatusMethodNotAllowed && w.Header().Get("Allow") == "" {
+		w.Header().Set("Allow", http.MethodGet)
+	}
+
+	varyAccept(w)
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(httpErr.Status)
+		json.NewEncoder(w).Encode(apiErrorBody{
+			Error: apiErrorDetail{Status: httpErr.Status, Message: httpErr.Message},
+		})
+		return
+	}
+
+	data := struct {
+		Title   string
+		Status  int
+		Heading string
+		Message string
+	}{
+		Title:   errorHeading(httpErr.Status) + " - Aurora Cars",
+		Status:  httpErr.Status,
+		Heading: errorHeading(httpErr.Status),
+		Message: httpErr.Message,
+	}
+
+	var buf bytes.Buffer
+	tmpl, devErr := app.currentTemplates()
+	if tmpl == nil {
+		http.Error(w, data.Heading+". "+data.Message, httpErr.Status)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(&buf, "error.html", data); err != nil {
+		log.Printf("Error executing template for error: %v", err)
+		http.Error(w, data.Heading+". "+data.Message, httpErr.Status)
+		return
+	}
+	app.writeHTML(w, httpErr.Status, buf.Bytes(), devErr)
+}
+
+// render executes a template into a buffer first, so a template error can
+// still be reported as a clean 500 instead of a half-written page.
+func (app *App) render(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	app.renderStatus(w, r, http.StatusOK, name, data)
+}
+
+func (app *App) renderStatus(w http.ResponseWriter, r *http.Request, status int, name string, data interface{}) {
+	var buf bytes.Buffer
+	tmpl, devErr := app.currentTemplates()
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		if app.dev != nil {
+			log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+			page := devOverlay("Template error", err, "The template parsed but failed while rendering this page.")
+			app.writeHTML(w, http.StatusInternalServerError, page, nil)
+			return
+		}
+		app.renderError(w, r, errInternal(err))
+		return
+	}
+	app.writeHTML(w, status, buf.Bytes(), devErr)
+}
+
+// writeHTML sends a rendered page. In development a failed template reload
+// is shown over it.
+func (app *App) writeHTML(w http.ResponseWriter, status int, page []byte, devErr error) {
+	if devErr != nil {
+		page = withDevOverlay(page, devErr)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(page)
+}
+
+// allowMethods answers 405 with an Allow header unless the request uses one
+// of methods.
+func (app *App) allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	if contains(methods, r.Method) || (r.Method == http.MethodHead && contains(methods, http.MethodGet)) {
+		return true
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	app.renderError(w, r, errMethodNotAllowed())
+	return false
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// exportRow is one car joined with its manufacturer and category, the shape
+// of both export formats.
+type exportRow struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	Year         int    `json:"year"`
+	Manufacturer string `json:"manufacturer"`
+	Country      string `json:"country"`
+	Category     string `json:"category"`
+	Engine       string `json:"engine"`
+	Horsepower   int    `json:"horsepower"`
+	Transmission string `json:"transmission"`
+	Drivetrain   string `json:"drivetrain"`
+	Image        string `json:"image"`
+}
+
+var exportColumns = []string{"id", "name", "year", "manufacturer", "country", "category", "engine", "horsepower", "transmission", "drivetrain", "image"}
+
+func (row exportRow) record() []string {
+	return []string{
+		strconv.Itoa(row.ID), row.Name, strconv.Itoa(row.Year), row.Manufacturer, row.Country,
+		row.Category, row.Engine, strconv.Itoa(row.Horsepower), row.Transmission, row.Drivetrain, row.Image,
+	}
+}
+
+// eachExportRow calls fn for every car in the catalog, in catalog order,
+// stopping at the first error.
+func (c catalog) eachExportRow(fn func(exportRow) error) error {
+	manufacturers := make(map[int]int, len(c.Manufacturers))
+	for i, m := range c.Manufacturers {
+		manufacturers[m.ID] = i
+	}
+	categories := make(map[int]string, len(c.Categories))
+	for _, category := range c.Categories {
+		categories[category.ID] = category.Name
+	}
+
+	for _, car := range c.CarModels {
+		row := exportRow{
+			ID:           car.ID,
+			Name:         car.Name,
+			Year:         car.Year,
+			Category:     categories[car.CategoryID],
+			Engine:       car.Specifications.Engine,
+			Horsepower:   car.Specifications.Horsepower,
+			Transmission: car.Specifications.Transmission,
+			Drivetrain:   car.Specifications.Drivetrain,
+			Image:        car.Image,
+		}
+		if i, ok := manufacturers[car.ManufacturerID]; ok {
+			row.Manufacturer = c.Manufacturers[i].Name
+			row.Country = c.Manufacturers[i].Country
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeNDJSON writes one JSON object per line. Rows go to w as they are
+// encoded, so memory use does not grow with the catalog.
+func writeNDJSON(w io.Writer, c catalog) error {
+	enc := json.NewEncoder(w)
+	return c.eachExportRow(func(row exportRow) error {
+		return enc.Encode(row)
+	})
+}
+
+func writeCSV(w io.Writer, c catalog) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return err
+	}
+	err := c.eachExportRow(func(row exportRow) error {
+		return cw.Write(row.record())
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (app *App) exportNDJSONHandler(w http.ResponseWriter, r *http.Request) {
+	app.serveExport(w, r, "application/x-ndjson", "catalog.ndjson", writeNDJSON)
+}
+
+func (app *App) exportCSVHandler(w http.ResponseWriter, r *http.Request) {
+	app.serveExport(w, r, "text/csv; charset=utf-8", "catalog.csv", writeCSV)
+}
+
+// serveExport streams the current snapshot. Once the first row is written
+// the status is sent, so a later write error can only be logged.
+func (app *App) serveExport(w http.ResponseWriter, r *http.Request, contentType, filename string, write func(io.Writer, catalog) error) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	c := app.snapshot()
+	if c.LoadedAt.IsZero() && len(c.CarModels) == 0 {
+		app.renderError(w, r, errUnavailable("The catalog has not been loaded yet. Please try again later.", nil))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if !c.LoadedAt.IsZero() {
+		w.Header().Set("Last-Modified", c.LoadedAt.UTC().Format(http.TimeFormat))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := write(w, c); err != nil {
+		log.Printf("Export of %s failed: %v", filename, err)
+	}
+}
+
+// runExport implements the "export" subcommand, which writes the same dumps
+// as /export/ from a data.json file without running the server or the API.
+// Like /export/, it leaves out records that are not live unless -all is
+// given.
+func runExport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "ndjson", "output format: ndjson or csv")
+	dataPath := fs.String("data", "api/data.json", "catalog file in the Cars API data.json format")
+	outPath := fs.String("o", "", "output file (default standard output)")
+	all := fs.Bool("all", false, "include draft, scheduled and archived records")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var write func(io.Writer, catalog) error
+	switch *format {
+	case "ndjson":
+		write = writeNDJSON
+	case "csv":
+		write = writeCSV
+	default:
+		return fmt.Errorf("unknown export format %q, use ndjson or csv", *format)
+	}
+
+	c, err := loadCatalogFile(*dataPath)
+	if err != nil {
+		return err
+	}
+	if !*all {
+		c = c.public(time.Now())
+	}
+
+	if *outPath == "" {
+		bw := bufio.NewWriter(stdout)
+		if err := write(bw, c); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+
+	f, err := os.Create(*outPath)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	if err := write(bw, c); err != nil {
+		f.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cars/structs"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportNDJSON(t *testing.T) {
+	app := setupCatalogApp(t)
+	req, rr := httptest.NewRequest("GET", "/export/catalog.ndjson", nil), httptest.NewRecorder()
+	app.exportNDJSONHandler(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("unexpected response %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	var rows []exportRow
+	scanner := bufio.NewScanner(rr.Body)
+	for scanner.Scan() {
+		var row exportRow
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	want := exportRow{ID: 3, Name: "Toyota Corolla", Year: 2023, Manufacturer: "Toyota", Country: "Japan", Category: "Sedan", Horsepower: 139}
+	if rows[2] != want {
+		t.Errorf("got %+v, want %+v", rows[2], want)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.catalog.LoadedAt = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	req, rr := httptest.NewRequest("GET", "/export/catalog.csv", nil), httptest.NewRecorder()
+	app.exportCSVHandler(rr, req)
+
+	if got := rr.Header().Get("Last-Modified"); got != "Wed, 01 May 2024 12:00:00 GMT" {
+		t.Errorf("Last-Modified should follow the snapshot time, got %q", got)
+	}
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || strings.Join(records[0], ",") != strings.Join(exportColumns, ",") {
+		t.Fatalf("unexpected CSV %v", records)
+	}
+	if got := strings.Join(records[1][:6], ","); got != "1,Mercedes-Benz GLE,2022,Mercedes-Benz,Germany,SUV" {
+		t.Errorf("unexpected first row %q", got)
+	}
+}
+
+func TestExport_UnavailableBeforeLoad(t *testing.T) {
+	app := &App{templates: testTemplates()}
+	req, rr := httptest.NewRequest("GET", "/export/catalog.csv", nil), httptest.NewRecorder()
+	app.exportCSVHandler(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before the catalog is loaded, got %d", rr.Code)
+	}
+}
+
+func TestRunExport(t *testing.T) {
+	dir := t.TempDir()
+	c := setupCatalogApp(t).snapshot()
+	c.CarModels = append([]structs.CarModel{}, c.CarModels...)
+	c.CarModels[0].Status = "draft"
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataPath := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(dataPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	outPath := filepath.Join(dir, "catalog.csv")
+	if err := runExport([]string{"-format", "csv", "-data", dataPath, "-o", outPath}, nil); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(out), "\n"); lines != 3 || strings.Contains(string(out), "Mercedes-Benz GLE") {
+		t.Errorf("expected header and the 2 published rows, got %d lines:\n%s", lines, out)
+	}
+
+	var all bytes.Buffer
+	if err := runExport([]string{"-all", "-data", dataPath}, &all); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(all.String(), "\n"); lines != 3 || !strings.Contains(all.String(), "Mercedes-Benz GLE") {
+		t.Errorf("-all should export the draft too, got %d lines:\n%s", lines, all.String())
+	}
+
+	if err := runExport([]string{"-format", "xml", "-data", dataPath}, nil); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
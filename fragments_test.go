@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, handler http.HandlerFunc, url string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, rr := httptest.NewRequest("GET", url, nil), httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestFragments_MatchFullPage(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	for _, name := range []string{"grid", "facets"} {
+		fragment := get(t, app.fragmentHandler, "/fragments/"+name+"?manufacturer=1&year=2023")
+		if fragment.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", name, fragment.Code)
+		}
+		body := strings.TrimSpace(fragment.Body.String())
+		if strings.Contains(body, "<html") || body == "" {
+			t.Errorf("%s: expected a bare fragment, got %s", name, body)
+		}
+
+		page := get(t, app.filterHandler, "/filter?manufacturer=1&year=2023").Body.String()
+		if !strings.Contains(page, body) {
+			t.Errorf("%s fragment differs from the full page:\n%s", name, body)
+		}
+	}
+
+	grid := get(t, app.fragmentHandler, "/fragments/grid?manufacturer=1&year=2023").Body.String()
+	if !strings.Contains(grid, "Mercedes-Benz E-Class") || strings.Contains(grid, "Mercedes-Benz GLE") {
+		t.Errorf("grid fragment should only hold the filtered cars:\n%s", grid)
+	}
+	facets := get(t, app.fragmentHandler, "/fragments/facets?manufacturer=1").Body.String()
+	if !strings.Contains(facets, `<option value="1" selected>Mercedes-Benz</option>`) {
+		t.Errorf("facets fragment should keep the selection:\n%s", facets)
+	}
+}
+
+func TestFragments_GridPagination(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	first := get(t, app.fragmentHandler, "/fragments/grid?category=2&per_page=1").Body.String()
+	if strings.Count(first, `class="grid-item"`) != 1 {
+		t.Fatalf("expected one car on the first page:\n%s", first)
+	}
+	if !strings.Contains(first, `data-next="/fragments/grid?category=2&amp;page=2&amp;per_page=1"`) {
+		t.Errorf("expected a link to the next page:\n%s", first)
+	}
+
+	last := get(t, app.fragmentHandler, "/fragments/grid?category=2&per_page=1&page=2").Body.String()
+	if !strings.Contains(last, "Toyota Corolla") || strings.Contains(last, "grid-sentinel") {
+		t.Errorf("the last page should hold the last car and no next link:\n%s", last)
+	}
+
+	for _, page := range []struct {
+		handler http.HandlerFunc
+		url     string
+	}{
+		{app.fragmentHandler, "/fragments/grid?page=9223372036854775807&per_page=100"},
+		{app.filterHandler, "/filter?page=9223372036854775807&per_page=100"},
+	} {
+		rr := get(t, page.handler, page.url)
+		if body := rr.Body.String(); rr.Code != http.StatusOK || strings.Contains(body, `class="grid-item"`) || strings.Contains(body, "grid-sentinel") {
+			t.Errorf("%s: expected an empty page, got %d:\n%s", page.url, rr.Code, body)
+		}
+	}
+}
+
+func TestFragments_Errors(t *testing.T) {
+	app := setupCatalogApp(t)
+	if rr := get(t, app.fragmentHandler, "/fragments/footer"); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown fragment: expected 404, got %d", rr.Code)
+	}
+	if rr := get(t, app.fragmentHandler, "/fragments/grid?page=0"); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid page: expected 400, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,485 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Execute parses, validates and runs a query against schema. Errors never
+// escape as a Go error; they are reported in the response as the spec
+// requires.
+func Execute(schema *Schema, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+	if errs := validate(schema, doc, op); len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+	vars, errs := coerceVariables(op, req.Variables)
+	if len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	e := &executor{ctx: ctx, doc: doc, vars: vars}
+	data, ok := e.executeSelections(schema.Query, nil, op.selectionSet, nil)
+	resp := &Response{Data: data, Errors: e.errors}
+	if !ok {
+		resp.Data = json.RawMessage("null")
+	}
+	return resp
+}
+
+func toError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return &Error{Message: err.Error()}
+}
+
+func selectOperation(doc *document, name string) (*operation, error) {
+	var op *operation
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		}
+		op = doc.operations[0]
+	} else {
+		for _, candidate := range doc.operations {
+			if candidate.name == name {
+				op = candidate
+			}
+		}
+		if op == nil {
+			return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+		}
+	}
+	if op.kind != "query" {
+		return nil, &Error{Message: fmt.Sprintf("Only query operations are supported, got %s.", op.kind), Locations: []Location{op.loc}}
+	}
+	return op, nil
+}
+
+// inputType resolves a variable's declared type. Only the built-in scalars
+// are valid inputs in this engine.
+func inputType(ref *typeRef) (Type, bool) {
+	var t Type
+	if ref.elem != nil {
+		elem, ok := inputType(ref.elem)
+		if !ok {
+			return nil, false
+		}
+		t = ListOf(elem)
+	} else {
+		scalar, ok := builtinScalars[ref.name]
+		if !ok {
+			return nil, false
+		}
+		t = scalar
+	}
+	if ref.nonNull {
+		t = NonNullOf(t)
+	}
+	return t, true
+}
+
+func coerceVariables(op *operation, values map[string]interface{}) (map[string]interface{}, []*Error) {
+	vars := make(map[string]interface{})
+	var errs []*Error
+	for _, def := range op.variables {
+		t, _ := inputType(def.typ)
+		value, present := values[def.name]
+		if !present {
+			if def.defaultVal != nil {
+				v, err := coerceInput(def.defaultVal, t)
+				if err != nil {
+					errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" has invalid default value: %v.", def.name, err), Locations: []Location{def.loc}})
+				}
+				vars[def.name] = v
+			} else if _, required := t.(*NonNull); required {
+				errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" of required type \"%s\" was not provided.", def.name, def.typ), Locations: []Location{def.loc}})
+			}
+			continue
+		}
+		v, err := coerceInput(value, t)
+		if err != nil {
+			errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" got invalid value: %v.", def.name, err), Locations: []Location{def.loc}})
+			continue
+		}
+		vars[def.name] = v
+	}
+	return vars, errs
+}
+
+// coerceInput converts a JSON variable value or a parsed literal (with any
+// variables already substituted) to the Go value resolvers receive.
+func coerceInput(v interface{}, t Type) (interface{}, error) {
+	if nn, ok := t.(*NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("expected non-null %s", t)
+		}
+		return coerceInput(v, nn.OfType)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case *Scalar:
+		if _, isEnum := v.(enumValue); !isEnum {
+			if parsed, ok := t.ParseValue(v); ok {
+				return parsed, nil
+			}
+		}
+		return nil, fmt.Errorf("%s cannot represent %s", t.Name, describeValue(v))
+	case *List:
+		items, ok := v.([]interface{})
+		if !ok {
+			// A single value is accepted where a list is expected.
+			item, err := coerceInput(v, t.OfType)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			var err error
+			if out[i], err = coerceInput(item, t.OfType); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+func describeValue(v interface{}) string {
+	switch v := v.(type) {
+	case enumValue:
+		return v.name
+	case string:
+		return fmt.Sprintf("%q", v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// substitute replaces variable references in a literal. The boolean is false
+// when the literal is a single variable that was not provided, in which case
+// the argument is treated as absent.
+func substitute(v interface{}, vars map[string]interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case variableRef:
+		value, ok := vars[v.name]
+		return value, ok
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i], _ = substitute(item, vars)
+		}
+		return out, true
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k], _ = substitute(item, vars)
+		}
+		return out, true
+	}
+	return v, true
+}
+
+func argumentValues(defs Args, given []*argument, vars map[string]interface{}) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	for name, def := range defs {
+		var literal interface{}
+		present := false
+		for _, arg := range given {
+			if arg.name == name {
+				literal, present = substitute(arg.value, vars)
+			}
+		}
+		if !present {
+			if def.Default != nil {
+				args[name] = def.Default
+			} else if _, required := def.Type.(*NonNull); required {
+				return nil, fmt.Errorf("Argument %q of required type %q was not provided.", name, def.Type)
+			}
+			continue
+		}
+		v, err := coerceInput(literal, def.Type)
+		if err != nil {
+			return nil, fmt.Errorf("Argument %q has invalid value: %v.", name, err)
+		}
+		args[name] = v
+	}
+	return args, nil
+}
+
+type executor struct {
+	ctx    context.Context
+	doc    *document
+	vars   map[string]interface{}
+	errors []*Error
+}
+
+func (e *executor) addError(message string, f *field, path []interface{}) {
+	e.errors = append(e.errors, &Error{Message: message, Locations: []Location{f.loc}, Path: path})
+}
+
+// included evaluates @skip and @include.
+func (e *executor) included(sel selection) bool {
+	for _, d := range sel.directiveList() {
+		args, err := argumentValues(Args{"if": {Type: NonNullOf(Boolean)}}, d.arguments, e.vars)
+		if err != nil {
+			continue
+		}
+		if d.name == "skip" && args["if"] == true {
+			return false
+		}
+		if d.name == "include" && args["if"] == false {
+			return false
+		}
+	}
+	return true
+}
+
+// collectFields flattens fragments into response keys, keeping the order in
+// which they first appear in the query.
+func (e *executor) collectFields(sels []selection, keys []string, groups map[string][]*field, visited map[string]bool) []string {
+	for _, sel := range sels {
+		if !e.included(sel) {
+			continue
+		}
+		switch sel := sel.(type) {
+		case *field:
+			key := sel.responseKey()
+			if _, seen := groups[key]; !seen {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], sel)
+		case *fragmentSpread:
+			if visited[sel.name] {
+				continue
+			}
+			visited[sel.name] = true
+			keys = e.collectFields(e.doc.fragments[sel.name].selectionSet, keys, groups, visited)
+		case *inlineFragment:
+			keys = e.collectFields(sel.selectionSet, keys, groups, visited)
+		}
+	}
+	return keys
+}
+
+// executeSelections resolves the fields of one object. It reports false when
+// a non-null field came back null, which makes the object itself null.
+func (e *executor) executeSelections(obj *Object, source interface{}, sels []selection, path []interface{}) (*resultMap, bool) {
+	groups := make(map[string][]*field)
+	keys := e.collectFields(sels, nil, groups, make(map[string]bool))
+
+	result := &resultMap{values: make(map[string]interface{}, len(keys))}
+	for _, key := range keys {
+		fields := groups[key]
+		fieldPath := append(append([]interface{}{}, path...), key)
+		result.keys = append(result.keys, key)
+
+		if fields[0].name == "__typename" {
+			result.values[key] = obj.Name
+			continue
+		}
+
+		def := obj.Fields[fields[0].name]
+		value, ok := e.resolveField(def, source, fields, fieldPath)
+		if ok {
+			value, ok = e.completeValue(def.Type, fields, value, fieldPath)
+		}
+		if !ok {
+			if _, nonNull := def.Type.(*NonNull); nonNull {
+				return nil, false
+			}
+			value = nil
+		}
+		result.values[key] = value
+	}
+	return result, true
+}
+
+func (e *executor) resolveField(def *Field, source interface{}, fields []*field, path []interface{}) (value interface{}, ok bool) {
+	args, err := argumentValues(def.Args, fields[0].arguments, e.vars)
+	if err != nil {
+		e.addError(err.Error(), fields[0], path)
+		return nil, false
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e.addError(fmt.Sprintf("internal error resolving %s", fields[0].name), fields[0], path)
+			value, ok = nil, false
+		}
+	}()
+
+	if def.Resolve == nil {
+		return defaultResolve(source, fields[0].name), true
+	}
+	value, err = def.Resolve(ResolveParams{Context: e.ctx, Source: source, Args: args})
+	if err != nil {
+		e.addError(err.Error(), fields[0], path)
+		return nil, false
+	}
+	return value, true
+}
+
+func (e *executor) completeValue(t Type, fields []*field, v interface{}, path []interface{}) (interface{}, bool) {
+	if nn, ok := t.(*NonNull); ok {
+		result, ok := e.completeValue(nn.OfType, fields, v, path)
+		if !ok {
+			return nil, false
+		}
+		if result == nil {
+			e.addError(fmt.Sprintf("Cannot return null for non-nullable field %s.", fields[0].name), fields[0], path)
+			return nil, false
+		}
+		return result, true
+	}
+	if isNil(v) {
+		return nil, true
+	}
+
+	switch t := t.(type) {
+	case *Scalar:
+		out, ok := t.Serialize(v)
+		if !ok {
+			e.addError(fmt.Sprintf("%s cannot represent value %v.", t.Name, v), fields[0], path)
+			return nil, false
+		}
+		return out, true
+	case *List:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.addError(fmt.Sprintf("Expected a list for field %s.", fields[0].name), fields[0], path)
+			return nil, false
+		}
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			itemPath := append(append([]interface{}{}, path...), i)
+			item, ok := e.completeValue(t.OfType, fields, rv.Index(i).Interface(), itemPath)
+			if !ok {
+				if _, nonNull := t.OfType.(*NonNull); nonNull {
+					return nil, false
+				}
+				item = nil
+			}
+			items[i] = item
+		}
+		return items, true
+	case *Object:
+		var sels []selection
+		for _, f := range fields {
+			sels = append(sels, f.selectionSet...)
+		}
+		result, ok := e.executeSelections(t, v, sels, path)
+		if !ok {
+			return nil, false
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// defaultResolve reads name from a map or from the struct field whose json
+// tag, or failing that whose Go name, matches.
+func defaultResolve(source interface{}, name string) interface{} {
+	if m, ok := source.(map[string]interface{}); ok {
+		return m[name]
+	}
+	rv := reflect.ValueOf(source)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	if v, ok := structField(rv, name); ok {
+		return v.Interface()
+	}
+	return nil
+}
+
+func structField(rv reflect.Value, name string) (reflect.Value, bool) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			if v, ok := structField(rv.Field(i), name); ok {
+				return v, true
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if tag == name || (tag == "" && strings.EqualFold(f.Name, name)) {
+			return rv.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// resultMap is a JSON object that keeps the field order of the query, as the
+// spec asks of serialized results.
+type resultMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *resultMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,217 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type author struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type book struct {
+	Title    string
+	AuthorID int
+}
+
+func testSchema() *Schema {
+	authors := []author{{1, "Ursula"}, {2, "Iain"}}
+	books := []book{{"Earthsea", 1}, {"Dispossessed", 1}, {"Excession", 2}}
+
+	authorType := &Object{Name: "Author"}
+	bookType := &Object{Name: "Book"}
+	authorType.Fields = Fields{
+		"id":   {Type: NonNullOf(ID)},
+		"name": {Type: NonNullOf(String)},
+		"books": {
+			Type: NonNullOf(ListOf(NonNullOf(bookType))),
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				var result []book
+				for _, b := range books {
+					if b.AuthorID == p.Source.(author).ID {
+						result = append(result, b)
+					}
+				}
+				return result, nil
+			},
+		},
+	}
+	bookType.Fields = Fields{
+		"title": {Type: NonNullOf(String)},
+		"author": {
+			Type: NonNullOf(authorType),
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				return authors[p.Source.(book).AuthorID-1], nil
+			},
+		},
+	}
+
+	return &Schema{
+		MaxDepth:      4,
+		MaxComplexity: 50,
+		Query: &Object{
+			Name: "Query",
+			Fields: Fields{
+				"author": {
+					Type: authorType,
+					Args: Args{"id": {Type: NonNullOf(Int)}},
+					Resolve: func(p ResolveParams) (interface{}, error) {
+						id := p.Args["id"].(int)
+						if id < 1 || id > len(authors) {
+							return nil, nil
+						}
+						return authors[id-1], nil
+					},
+				},
+				"authors": {
+					Type: ListOf(authorType),
+					Args: Args{"limit": {Type: Int, Default: 10}},
+					Resolve: func(p ResolveParams) (interface{}, error) {
+						return authors[:min(p.Args["limit"].(int), len(authors))], nil
+					},
+				},
+				"broken": {
+					Type: NonNullOf(String),
+					Resolve: func(p ResolveParams) (interface{}, error) {
+						return nil, errors.New("boom")
+					},
+				},
+				"maybe": {
+					Type: String,
+					Resolve: func(p ResolveParams) (interface{}, error) {
+						return nil, errors.New("not today")
+					},
+				},
+			},
+		},
+	}
+}
+
+func run(t *testing.T, query string, vars map[string]interface{}) (string, *Response) {
+	t.Helper()
+	resp := Execute(testSchema(), Request{Query: query, Variables: vars})
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), resp
+}
+
+func TestExecute_NestedFieldsKeepQueryOrder(t *testing.T) {
+	got, _ := run(t, `{ author(id: 1) { name id books { title } } }`, nil)
+	want := `{"data":{"author":{"name":"Ursula","id":"1","books":[{"title":"Earthsea"},{"title":"Dispossessed"}]}}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestExecute_AliasesFragmentsAndDirectives(t *testing.T) {
+	query := `
+		query Pair($first: Int!, $withBooks: Boolean = false) {
+			a: author(id: $first) { ...names books @include(if: $withBooks) { title } }
+			b: author(id: 2) { ... on Author { __typename name } }
+		}
+		fragment names on Author { name }`
+	got, _ := run(t, query, map[string]interface{}{"first": float64(2)})
+	want := `{"data":{"a":{"name":"Iain"},"b":{"__typename":"Author","name":"Iain"}}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestExecute_ArgumentDefaults(t *testing.T) {
+	got, _ := run(t, `{ authors { id } one: authors(limit: 1) { id } }`, nil)
+	want := `{"data":{"authors":[{"id":"1"},{"id":"2"}],"one":[{"id":"1"}]}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestExecute_ResolverErrors(t *testing.T) {
+	got, resp := run(t, `{ maybe author(id: 1) { name } }`, nil)
+	if !strings.Contains(got, `"data":{"maybe":null,"author":{"name":"Ursula"}}`) {
+		t.Errorf("a nullable field error should leave the rest of the data: %s", got)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Message != "not today" || resp.Errors[0].Path[0] != "maybe" {
+		t.Errorf("unexpected errors %+v", resp.Errors)
+	}
+
+	got, _ = run(t, `{ broken author(id: 1) { name } }`, nil)
+	if !strings.HasPrefix(got, `{"data":null,"errors":[{"message":"boom"`) {
+		t.Errorf("a non-null root field error should null the data: %s", got)
+	}
+}
+
+func TestExecute_RejectsInvalidQueries(t *testing.T) {
+	tests := map[string]string{
+		`{ author(id: 1) { name `:                                              "Syntax error",
+		`{ author(id: 1) { nope } }`:                                           `Cannot query field "nope" on type "Author".`,
+		`{ author { name } }`:                                                  `Argument "id" of type "Int!" is required`,
+		`{ author(id: "x") { name } }`:                                         `Argument "id" has invalid value`,
+		`{ author(id: 1) }`:                                                    "must have a selection of subfields",
+		`{ author(id: 1) { name { x } } }`:                                     "must not have a selection",
+		`{ author(id: $id) { name } }`:                                         `Variable "$id" is not defined.`,
+		`{ author(id: 1) { ...f } } fragment f on Author { ...f }`:             `Cannot spread fragment "f" within itself.`,
+		`{ author(id: 1) { books { author { books { author { name } } } } } }`: "maximum depth is 4",
+		`mutation { author(id: 1) { name } }`:                                  "Only query operations are supported",
+		`{ author(id: 1) { ...n books { author { ...n } } } } fragment n on Author { books { title } }`: "maximum depth is 4",
+	}
+	for query, want := range tests {
+		got, resp := run(t, query, nil)
+		if resp.Data != nil {
+			t.Errorf("%s: invalid queries must not execute, got %s", query, got)
+		}
+		if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, want) {
+			t.Errorf("%s: expected error containing %q, got %s", query, want, got)
+		}
+	}
+}
+
+func TestExecute_RejectsFragmentBlowup(t *testing.T) {
+	// Each fragment spreads the next twice, so the query expands to 2^60
+	// fields. Validation must walk each fragment once and reject it.
+	var query strings.Builder
+	query.WriteString("{ ...f0 }")
+	for i := 0; i < 60; i++ {
+		fmt.Fprintf(&query, " fragment f%d on Query { ...f%d ...f%d }", i, i+1, i+1)
+	}
+	query.WriteString(" fragment f60 on Query { authors { name } }")
+
+	got, resp := run(t, query.String(), nil)
+	if resp.Data != nil || len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "maximum of 50 fields") {
+		t.Errorf("expected the query to be rejected as too complex, got %s", got)
+	}
+
+	if got, resp := run(t, `{ a: author(id: 1) { ...n } b: author(id: 2) { ...n } } fragment n on Author { name }`, nil); resp.Errors != nil {
+		t.Errorf("a fragment spread twice is fine, got %s", got)
+	}
+}
+
+func TestExecute_Variables(t *testing.T) {
+	query := `query($id: Int!) { author(id: $id) { name } }`
+	if got, _ := run(t, query, map[string]interface{}{"id": float64(1)}); got != `{"data":{"author":{"name":"Ursula"}}}` {
+		t.Errorf("unexpected result %s", got)
+	}
+	if got, resp := run(t, query, nil); len(resp.Errors) == 0 || resp.Errors[0].Message != `Variable "$id" of required type "Int!" was not provided.` {
+		t.Errorf("missing variable should be reported, got %s", got)
+	}
+	if got, _ := run(t, query, map[string]interface{}{"id": 1.5}); !strings.Contains(got, "Int cannot represent 1.5") {
+		t.Errorf("invalid variable should be reported, got %s", got)
+	}
+}
+
+func TestExecute_OperationName(t *testing.T) {
+	query := `query A { author(id: 1) { name } } query B { author(id: 2) { name } }`
+	resp := Execute(testSchema(), Request{Query: query, OperationName: "B"})
+	b, _ := json.Marshal(resp)
+	if string(b) != `{"data":{"author":{"name":"Iain"}}}` {
+		t.Errorf("unexpected result %s", b)
+	}
+	if resp := Execute(testSchema(), Request{Query: query}); len(resp.Errors) == 0 {
+		t.Errorf("ambiguous operation should be an error")
+	}
+}
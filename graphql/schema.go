@@ -0,0 +1,241 @@
+// Package graphql is a small GraphQL query engine built on the standard
+// library only. It parses and validates queries, enforces a depth limit and
+// executes them against a schema of Go resolvers. Mutations, subscriptions
+// and introspection are not supported.
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+type Type interface {
+	String() string
+}
+
+// Scalar is a leaf type. Serialize turns a resolved Go value into its JSON
+// form; ParseValue coerces a variable or literal into the Go value passed to
+// resolvers, reporting false when the input is not valid for the type.
+type Scalar struct {
+	Name       string
+	Serialize  func(v interface{}) (interface{}, bool)
+	ParseValue func(v interface{}) (interface{}, bool)
+}
+
+func (s *Scalar) String() string { return s.Name }
+
+type Object struct {
+	Name   string
+	Fields Fields
+}
+
+func (o *Object) String() string { return o.Name }
+
+type List struct {
+	OfType Type
+}
+
+func (l *List) String() string { return "[" + l.OfType.String() + "]" }
+
+type NonNull struct {
+	OfType Type
+}
+
+func (n *NonNull) String() string { return n.OfType.String() + "!" }
+
+func ListOf(t Type) *List { return &List{OfType: t} }
+
+func NonNullOf(t Type) *NonNull { return &NonNull{OfType: t} }
+
+type Fields map[string]*Field
+
+// Field describes one field of an Object. When Resolve is nil the value is
+// read from the parent: a map key, or a struct field matched by its json tag
+// or name.
+type Field struct {
+	Type        Type
+	Args        Args
+	Resolve     ResolveFunc
+	Description string
+}
+
+type Args map[string]*Arg
+
+type Arg struct {
+	Type    Type
+	Default interface{}
+}
+
+type ResolveParams struct {
+	Context context.Context
+	Source  interface{}
+	Args    map[string]interface{}
+}
+
+type ResolveFunc func(p ResolveParams) (interface{}, error)
+
+type Schema struct {
+	Query *Object
+	// MaxDepth rejects queries nesting fields deeper than this. Zero means
+	// no limit.
+	MaxDepth int
+	// MaxComplexity rejects queries that select more fields than this, with
+	// a fragment's fields counted again at every spread. List lengths are
+	// not known before execution and do not count. Zero means no limit.
+	MaxComplexity int
+}
+
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type Request struct {
+	Context       context.Context
+	Query         string
+	Variables     map[string]interface{}
+	OperationName string
+}
+
+// Response follows the GraphQL spec: Data is absent when the request failed
+// before execution started, and null when a non-null root field failed.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+var (
+	Int = &Scalar{
+		Name: "Int",
+		Serialize: func(v interface{}) (interface{}, bool) {
+			n, ok := toInt(v)
+			return n, ok
+		},
+		ParseValue: func(v interface{}) (interface{}, bool) {
+			n, ok := toInt(v)
+			return n, ok
+		},
+	}
+	Float = &Scalar{
+		Name: "Float",
+		Serialize: func(v interface{}) (interface{}, bool) {
+			return toFloat(v)
+		},
+		ParseValue: func(v interface{}) (interface{}, bool) {
+			return toFloat(v)
+		},
+	}
+	String = &Scalar{
+		Name: "String",
+		Serialize: func(v interface{}) (interface{}, bool) {
+			if s, ok := v.(fmt.Stringer); ok {
+				return s.String(), true
+			}
+			s, ok := v.(string)
+			return s, ok
+		},
+		ParseValue: func(v interface{}) (interface{}, bool) {
+			s, ok := v.(string)
+			return s, ok
+		},
+	}
+	Boolean = &Scalar{
+		Name: "Boolean",
+		Serialize: func(v interface{}) (interface{}, bool) {
+			b, ok := v.(bool)
+			return b, ok
+		},
+		ParseValue: func(v interface{}) (interface{}, bool) {
+			b, ok := v.(bool)
+			return b, ok
+		},
+	}
+	ID = &Scalar{
+		Name: "ID",
+		Serialize: func(v interface{}) (interface{}, bool) {
+			if n, ok := toInt(v); ok {
+				return strconv.Itoa(n), true
+			}
+			s, ok := v.(string)
+			return s, ok
+		},
+		ParseValue: func(v interface{}) (interface{}, bool) {
+			if n, ok := toInt(v); ok {
+				return strconv.Itoa(n), true
+			}
+			s, ok := v.(string)
+			return s, ok
+		},
+	}
+)
+
+var builtinScalars = map[string]*Scalar{
+	"Int":     Int,
+	"Float":   Float,
+	"String":  String,
+	"Boolean": Boolean,
+	"ID":      ID,
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return 0, false
+		}
+		return int(n), true
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt32 || n > math.MaxInt32 {
+			return 0, false
+		}
+		return int(n), true
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) (interface{}, bool) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	if i, ok := toInt(v); ok {
+		return float64(i), true
+	}
+	return nil, false
+}
+
+// namedType strips List and NonNull wrappers.
+func namedType(t Type) Type {
+	for {
+		switch w := t.(type) {
+		case *List:
+			t = w.OfType
+		case *NonNull:
+			t = w.OfType
+		default:
+			return t
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package graphql
+
+import (
+	"fmt"
+	"math"
+)
+
+type validator struct {
+	schema    *Schema
+	doc       *document
+	vars      map[string]Type
+	errors    []*Error
+	tooDeep   bool
+	spreading map[string]bool
+	// fragments holds the cost of each fragment validated so far, so a
+	// fragment spread many times is only walked once.
+	fragments map[string]cost
+}
+
+// cost is what a selection set adds to a query: the deepest field nesting
+// it reaches and the number of fields it expands to, counting a fragment's
+// fields once for every spread.
+type cost struct {
+	depth  int
+	fields int
+}
+
+func (c cost) add(other cost) cost {
+	fields := math.MaxInt
+	if c.fields <= math.MaxInt-other.fields {
+		fields = c.fields + other.fields
+	}
+	return cost{depth: max(c.depth, other.depth), fields: fields}
+}
+
+// validate checks the selected operation against the schema before anything
+// is resolved, so that malformed, overly deep or overly large queries cost
+// nothing.
+func validate(schema *Schema, doc *document, op *operation) []*Error {
+	v := &validator{schema: schema, doc: doc, vars: make(map[string]Type), spreading: make(map[string]bool), fragments: make(map[string]cost)}
+	for _, def := range op.variables {
+		t, ok := inputType(def.typ)
+		if !ok {
+			v.errorf(def.loc, "Unknown type \"%s\" for variable \"$%s\".", def.typ, def.name)
+			continue
+		}
+		// A nullable variable with a default may fill a non-null slot.
+		if _, nonNull := t.(*NonNull); !nonNull && def.defaultVal != nil {
+			t = NonNullOf(t)
+		}
+		v.vars[def.name] = t
+	}
+	total := v.selections(schema.Query, op.selectionSet, 1)
+	if schema.MaxComplexity > 0 && total.fields > schema.MaxComplexity {
+		v.errorf(op.loc, "Query is too complex: it selects more than the maximum of %d fields.", schema.MaxComplexity)
+	}
+	return v.errors
+}
+
+func (v *validator) errorf(loc Location, format string, args ...interface{}) {
+	v.errors = append(v.errors, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}})
+}
+
+// selections validates sels at the given depth and returns their cost, with
+// depth counted from the operation's root fields.
+func (v *validator) selections(obj *Object, sels []selection, depth int) cost {
+	var total cost
+	for _, sel := range sels {
+		v.directives(sel)
+		switch sel := sel.(type) {
+		case *field:
+			total = total.add(v.field(obj, sel, depth))
+		case *fragmentSpread:
+			frag, ok := v.doc.fragments[sel.name]
+			if !ok {
+				v.errorf(sel.loc, "Unknown fragment \"%s\".", sel.name)
+				continue
+			}
+			if frag.typeCondition != obj.Name {
+				v.errorf(sel.loc, "Fragment \"%s\" cannot be spread here as objects of type \"%s\" can never be of type \"%s\".", sel.name, obj.Name, frag.typeCondition)
+				continue
+			}
+			if v.spreading[sel.name] {
+				v.errorf(sel.loc, "Cannot spread fragment \"%s\" within itself.", sel.name)
+				continue
+			}
+			fragCost := v.fragment(obj, frag)
+			fragCost.depth += depth - 1
+			v.checkDepth(sel.loc, fragCost.depth)
+			total = total.add(fragCost)
+		case *inlineFragment:
+			if sel.typeCondition != "" && sel.typeCondition != obj.Name {
+				v.errorf(sel.loc, "Fragment cannot be spread here as objects of type \"%s\" can never be of type \"%s\".", obj.Name, sel.typeCondition)
+				continue
+			}
+			total = total.add(v.selections(obj, sel.selectionSet, depth))
+		}
+	}
+	return total
+}
+
+// fragment validates a fragment the first time it is spread and returns its
+// cost as if it were spread at the root. Later spreads reuse that cost, so a
+// chain of fragments that each spread the next several times is not walked
+// once per path through it.
+func (v *validator) fragment(obj *Object, frag *fragment) cost {
+	if c, ok := v.fragments[frag.name]; ok {
+		return c
+	}
+	v.spreading[frag.name] = true
+	c := v.selections(obj, frag.selectionSet, 1)
+	delete(v.spreading, frag.name)
+	v.fragments[frag.name] = c
+	return c
+}
+
+// checkDepth reports the first field nested deeper than the schema allows.
+func (v *validator) checkDepth(loc Location, depth int) bool {
+	if v.schema.MaxDepth == 0 || depth <= v.schema.MaxDepth {
+		return true
+	}
+	if !v.tooDeep {
+		v.errorf(loc, "Query is nested too deep: the maximum depth is %d.", v.schema.MaxDepth)
+		v.tooDeep = true
+	}
+	return false
+}
+
+func (v *validator) field(obj *Object, f *field, depth int) cost {
+	own := cost{depth: depth, fields: 1}
+	if !v.checkDepth(f.loc, depth) {
+		return own
+	}
+
+	if f.name == "__typename" {
+		if f.selectionSet != nil {
+			v.errorf(f.loc, "Field \"__typename\" must not have a selection since type \"String!\" has no subfields.")
+		}
+		return own
+	}
+	def, ok := obj.Fields[f.name]
+	if !ok {
+		v.errorf(f.loc, "Cannot query field \"%s\" on type \"%s\".", f.name, obj.Name)
+		return own
+	}
+	v.arguments(f.name, def.Args, f.arguments, f.loc)
+
+	child, isObject := namedType(def.Type).(*Object)
+	switch {
+	case isObject && f.selectionSet == nil:
+		v.errorf(f.loc, "Field \"%s\" of type \"%s\" must have a selection of subfields.", f.name, def.Type)
+	case !isObject && f.selectionSet != nil:
+		v.errorf(f.loc, "Field \"%s\" must not have a selection since type \"%s\" has no subfields.", f.name, def.Type)
+	case isObject:
+		return own.add(v.selections(child, f.selectionSet, depth+1))
+	}
+	return own
+}
+
+func (v *validator) arguments(owner string, defs Args, given []*argument, loc Location) {
+	seen := make(map[string]bool)
+	for _, arg := range given {
+		if seen[arg.name] {
+			v.errorf(arg.loc, "There can be only one argument named \"%s\".", arg.name)
+			continue
+		}
+		seen[arg.name] = true
+		def, ok := defs[arg.name]
+		if !ok {
+			v.errorf(arg.loc, "Unknown argument \"%s\" on \"%s\".", arg.name, owner)
+			continue
+		}
+		v.value(arg, def.Type)
+	}
+	for name, def := range defs {
+		if _, required := def.Type.(*NonNull); required && def.Default == nil && !seen[name] {
+			v.errorf(loc, "Argument \"%s\" of type \"%s\" is required on \"%s\", but it was not provided.", name, def.Type, owner)
+		}
+	}
+}
+
+// value checks a literal argument. Arguments that are exactly a variable are
+// checked against the variable's declared type instead, since the value is
+// only known at execution time.
+func (v *validator) value(arg *argument, t Type) {
+	if ref, ok := arg.value.(variableRef); ok {
+		declared, ok := v.vars[ref.name]
+		if !ok {
+			v.errorf(arg.loc, "Variable \"$%s\" is not defined.", ref.name)
+			return
+		}
+		if !compatible(declared, t) {
+			v.errorf(arg.loc, "Variable \"$%s\" of type \"%s\" used in position expecting type \"%s\".", ref.name, declared, t)
+		}
+		return
+	}
+	if name, ok := v.undefinedVariable(arg.value); ok {
+		v.errorf(arg.loc, "Variable \"$%s\" is not defined.", name)
+		return
+	}
+	if hasVariable(arg.value) {
+		return
+	}
+	if _, err := coerceInput(arg.value, t); err != nil {
+		v.errorf(arg.loc, "Argument \"%s\" has invalid value: %v.", arg.name, err)
+	}
+}
+
+func (v *validator) undefinedVariable(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case variableRef:
+		_, ok := v.vars[value.name]
+		return value.name, !ok
+	case []interface{}:
+		for _, item := range value {
+			if name, ok := v.undefinedVariable(item); ok {
+				return name, true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			if name, ok := v.undefinedVariable(item); ok {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+func hasVariable(value interface{}) bool {
+	switch value := value.(type) {
+	case variableRef:
+		return true
+	case []interface{}:
+		for _, item := range value {
+			if hasVariable(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			if hasVariable(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compatible reports whether a variable of type declared may be used where
+// expected is required. A nullable variable may not fill a non-null slot.
+func compatible(declared, expected Type) bool {
+	if nn, ok := expected.(*NonNull); ok {
+		d, ok := declared.(*NonNull)
+		return ok && compatible(d.OfType, nn.OfType)
+	}
+	if d, ok := declared.(*NonNull); ok {
+		return compatible(d.OfType, expected)
+	}
+	if l, ok := expected.(*List); ok {
+		d, ok := declared.(*List)
+		return ok && compatible(d.OfType, l.OfType)
+	}
+	return declared == expected
+}
+
+func (v *validator) directives(sel selection) {
+	for _, d := range sel.directiveList() {
+		if d.name != "skip" && d.name != "include" {
+			v.errorf(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		v.arguments("@"+d.name, Args{"if": {Type: NonNullOf(Boolean)}}, d.arguments, d.loc)
+	}
+}
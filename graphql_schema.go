@@ -0,0 +1,251 @@
+package main
+
+import (
+	"cars/graphql"
+	"cars/structs"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// graphQLSchema exposes the catalog with its relationships, so a client can
+// fetch a car, its manufacturer, sibling models and category in one request.
+// Filter arguments mirror the JSON API and reuse filterCars. Every resolver
+// reads c, so one query sees one catalog throughout.
+func (app *App) graphQLSchema(c catalog) *graphql.Schema {
+	specifications := &graphql.Object{
+		Name: "Specifications",
+		Fields: graphql.Fields{
+			"engine":       {Type: graphql.NonNullOf(graphql.String)},
+			"horsepower":   {Type: graphql.NonNullOf(graphql.Int)},
+			"transmission": {Type: graphql.NonNullOf(graphql.String)},
+			"drivetrain":   {Type: graphql.NonNullOf(graphql.String)},
+		},
+	}
+	car := &graphql.Object{Name: "Car"}
+	manufacturer := &graphql.Object{Name: "Manufacturer"}
+	category := &graphql.Object{Name: "Category"}
+
+	car.Fields = graphql.Fields{
+		"id":             {Type: graphql.NonNullOf(graphql.ID)},
+		"name":           {Type: graphql.NonNullOf(graphql.String)},
+		"year":           {Type: graphql.NonNullOf(graphql.Int)},
+		"image":          {Type: graphql.NonNullOf(graphql.String)},
+		"specifications": {Type: graphql.NonNullOf(specifications)},
+		"url": {
+			Type: graphql.NonNullOf(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return carURL(p.Source.(structs.CarModel)), nil
+			},
+		},
+		"manufacturer": {
+			Type: manufacturer,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return c.manufacturerByID(p.Source.(structs.CarModel).ManufacturerID), nil
+			},
+		},
+		"category": {
+			Type: category,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return c.categoryByID(p.Source.(structs.CarModel).CategoryID), nil
+			},
+		},
+	}
+
+	manufacturer.Fields = graphql.Fields{
+		"id":           {Type: graphql.NonNullOf(graphql.ID)},
+		"name":         {Type: graphql.NonNullOf(graphql.String)},
+		"country":      {Type: graphql.NonNullOf(graphql.String)},
+		"foundingYear": {Type: graphql.NonNullOf(graphql.Int)},
+		"models": {
+			Type: graphql.NonNullOf(graphql.ListOf(graphql.NonNullOf(car))),
+			Args: graphql.Args{
+				"category": {Type: graphql.ID},
+				"year":     {Type: graphql.Int},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				filter := graphQLCarFilter(p.Args)
+				filter.Manufacturer = strconv.Itoa(p.Source.(*structs.Manufacturer).ID)
+				return c.filterCars(filter), nil
+			},
+		},
+	}
+
+	category.Fields = graphql.Fields{
+		"id":   {Type: graphql.NonNullOf(graphql.ID)},
+		"name": {Type: graphql.NonNullOf(graphql.String)},
+		"models": {
+			Type: graphql.NonNullOf(graphql.ListOf(graphql.NonNullOf(car))),
+			Args: graphql.Args{
+				"manufacturer": {Type: graphql.ID},
+				"year":         {Type: graphql.Int},
+				"country":      {Type: graphql.String},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				filter := graphQLCarFilter(p.Args)
+				filter.Category = strconv.Itoa(p.Source.(*structs.Category).ID)
+				return c.filterCars(filter), nil
+			},
+		},
+	}
+
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"car": {
+				Type: car,
+				Args: graphql.Args{"id": {Type: graphql.NonNullOf(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := strconv.Atoi(p.Args["id"].(string))
+					if err != nil {
+						return nil, nil
+					}
+					if found, _ := c.findCar(id); found != nil {
+						return *found, nil
+					}
+					return nil, nil
+				},
+			},
+			"cars": {
+				Type: graphql.NonNullOf(graphql.ListOf(graphql.NonNullOf(car))),
+				Args: graphql.Args{
+					"manufacturer": {Type: graphql.ID},
+					"category":     {Type: graphql.ID},
+					"year":         {Type: graphql.Int},
+					"country":      {Type: graphql.String},
+					"query":        {Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return c.filterCars(graphQLCarFilter(p.Args)), nil
+				},
+			},
+			"manufacturer": {
+				Type: manufacturer,
+				Args: graphql.Args{"id": {Type: graphql.NonNullOf(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return c.findManufacturer(p.Args["id"].(string)), nil
+				},
+			},
+			"manufacturers": {
+				Type: graphql.NonNullOf(graphql.ListOf(graphql.NonNullOf(manufacturer))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					result := make([]*structs.Manufacturer, len(c.Manufacturers))
+					for i := range c.Manufacturers {
+						result[i] = &c.Manufacturers[i]
+					}
+					return result, nil
+				},
+			},
+			"category": {
+				Type: category,
+				Args: graphql.Args{"id": {Type: graphql.NonNullOf(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := strconv.Atoi(p.Args["id"].(string))
+					if err != nil {
+						return nil, nil
+					}
+					return c.categoryByID(id), nil
+				},
+			},
+			"categories": {
+				Type: graphql.NonNullOf(graphql.ListOf(graphql.NonNullOf(category))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					result := make([]*structs.Category, len(c.Categories))
+					for i := range c.Categories {
+						result[i] = &c.Categories[i]
+					}
+					return result, nil
+				},
+			},
+		},
+	}
+
+	return &graphql.Schema{
+		Query:         query,
+		MaxDepth:      app.config.GraphQL.MaxDepth,
+		MaxComplexity: app.config.GraphQL.MaxComplexity,
+	}
+}
+
+func graphQLCarFilter(args map[string]interface{}) carFilter {
+	var filter carFilter
+	if v, ok := args["manufacturer"].(string); ok {
+		filter.Manufacturer = v
+	}
+	if v, ok := args["category"].(string); ok {
+		filter.Category = v
+	}
+	if v, ok := args["year"].(int); ok {
+		filter.Year = strconv.Itoa(v)
+	}
+	if v, ok := args["country"].(string); ok {
+		filter.Country = v
+	}
+	if v, ok := args["query"].(string); ok {
+		filter.Query = strings.ToLower(v)
+	}
+	return filter
+}
+
+func (c catalog) manufacturerByID(id int) *structs.Manufacturer {
+	for i := range c.Manufacturers {
+		if c.Manufacturers[i].ID == id {
+			return &c.Manufacturers[i]
+		}
+	}
+	return nil
+}
+
+func (c catalog) categoryByID(id int) *structs.Category {
+	for i := range c.Categories {
+		if c.Categories[i].ID == id {
+			return &c.Categories[i]
+		}
+	}
+	return nil
+}
+
+// graphQLHandler serves queries over GET (query, variables and
+// operationName as URL parameters) and POST with a JSON body. Query errors
+// are reported in the GraphQL response body with status 200; only requests
+// that are not GraphQL requests at all get an HTTP error.
+func (app *App) graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+
+	var req graphQLRequest
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			app.renderError(w, r, errBadRequest("Request body must be a JSON object with a query."))
+			return
+		}
+	} else {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				app.renderError(w, r, errBadRequest("The variables parameter must be a JSON object."))
+				return
+			}
+		}
+	}
+	if req.Query == "" {
+		app.renderError(w, r, errBadRequest("Missing query."))
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, graphql.Execute(app.graphQLSchema(app.snapshot()), graphql.Request{
+		Context:       r.Context(),
+		Query:         req.Query,
+		Variables:     req.Variables,
+		OperationName: req.OperationName,
+	}))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func postGraphQL(t *testing.T, app *App, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	app.graphQLHandler(rr, req)
+	return rr.Code, rr.Body.String()
+}
+
+func compactJSON(t *testing.T, s string) string {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid JSON %q: %v", s, err)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestGraphQL_CarWithRelationships(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.config = defaultConfig()
+
+	query := `{"query": "query($id: ID!) { car(id: $id) { name url manufacturer { name models(year: 2023) { name } } category { name models(country: \"Japan\") { id } } } }", "variables": {"id": 2}}`
+	code, body := postGraphQL(t, app, query)
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", code, body)
+	}
+
+	want := `{"data":{"car":{"category":{"models":[{"id":"3"}],"name":"Sedan"},"manufacturer":{"models":[{"name":"Mercedes-Benz E-Class"}],"name":"Mercedes-Benz"},"name":"Mercedes-Benz E-Class","url":"/cars/2/mercedes-benz-e-class-2023"}}}`
+	if got := compactJSON(t, body); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestGraphQL_FiltersAndGet(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.config = defaultConfig()
+
+	params := url.Values{"query": {`{ cars(country: "Germany", category: 1) { id } manufacturers { name } }`}}
+	req, rr := httptest.NewRequest("GET", "/graphql?"+params.Encode(), nil), httptest.NewRecorder()
+	app.graphQLHandler(rr, req)
+
+	want := `{"data":{"cars":[{"id":"1"}],"manufacturers":[{"name":"Mercedes-Benz"},{"name":"Toyota"}]}}`
+	if got := compactJSON(t, rr.Body.String()); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestGraphQL_DepthLimitFromConfig(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.config = defaultConfig()
+	app.config.GraphQL.MaxDepth = 3
+
+	_, body := postGraphQL(t, app, `{"query": "{ car(id: 1) { manufacturer { models { category { name } } } } }"}`)
+	if !strings.Contains(body, "maximum depth is 3") || strings.Contains(body, `"data"`) {
+		t.Errorf("expected depth limit error without data, got %s", body)
+	}
+}
+
+func TestGraphQL_ComplexityLimitFromConfig(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.config = defaultConfig()
+	app.config.GraphQL.MaxComplexity = 3
+
+	_, body := postGraphQL(t, app, `{"query": "{ a: cars { ...f } b: cars { ...f } } fragment f on Car { id name }"}`)
+	if !strings.Contains(body, "maximum of 3 fields") || strings.Contains(body, `"data"`) {
+		t.Errorf("expected complexity limit error without data, got %s", body)
+	}
+}
+
+func TestGraphQL_BadRequests(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.config = defaultConfig()
+
+	for _, body := range []string{`not json`, `{"query": ""}`} {
+		code, out := postGraphQL(t, app, body)
+		if code != http.StatusBadRequest || !strings.Contains(out, `"error"`) {
+			t.Errorf("%s: expected a JSON 400, got %d: %s", body, code, out)
+		}
+	}
+
+	req, rr := httptest.NewRequest("DELETE", "/graphql", nil), httptest.NewRecorder()
+	app.graphQLHandler(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// imageWidths are the widths /img/{name}?w= resizes to. Keeping the list
+// short bounds the work and the cache a single photo can cause.
+var imageWidths = []int{320, 640, 960, 1280}
+
+// imageCacheBytes bounds the memory held by resized photos.
+const imageCacheBytes = 32 << 20
+
+// Photos named after their content, like uploads, never change, so they
+// may be cached for good; hand-placed photos may be replaced under the same
+// name.
+var contentNamedImage = regexp.MustCompile(`^[0-9a-f]{16}\.(jpg|png)$`)
+
+// resizedImage is one cached variant. The key includes the source's size
+// and modification time, so replacing a photo leaves its old variants to
+// age out of the cache.
+type resizedImage struct {
+	key         string
+	data        []byte
+	contentType string
+	etag        string
+}
+
+// imageCache is a least-recently-used cache of resized photos, bounded by
+// the total size of their bytes. The zero value holds up to
+// imageCacheBytes.
+type imageCache struct {
+	mu      sync.Mutex
+	limit   int
+	size    int
+	order   list.List
+	entries map[string]*list.Element
+}
+
+func (c *imageCache) get(key string) (resizedImage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return resizedImage{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(resizedImage), true
+}
+
+func (c *imageCache) put(img resizedImage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	if c.limit == 0 {
+		c.limit = imageCacheBytes
+	}
+	if len(img.data) > c.limit {
+		return
+	}
+	if e, ok := c.entries[img.key]; ok {
+		c.size -= len(e.Value.(resizedImage).data)
+		c.order.Remove(e)
+	}
+	c.entries[img.key] = c.order.PushFront(img)
+	c.size += len(img.data)
+	for c.size > c.limit {
+		oldest := c.order.Back()
+		evicted := c.order.Remove(oldest).(resizedImage)
+		delete(c.entries, evicted.key)
+		c.size -= len(evicted.data)
+	}
+}
+
+// imageSrcset lists the resized variants of a photo for an img srcset:
+// every width narrower than the photo, and the photo itself at its own
+// width. A photo whose width cannot be read gets every variant.
+func imageSrcset(name string) string {
+	source, ok := imageWidth(name)
+	var parts []string
+	for _, width := range imageWidths {
+		if ok && width >= source {
+			break
+		}
+		parts = append(parts, fmt.Sprintf("/img/%s?w=%d %dw", name, width, width))
+	}
+	if ok {
+		parts = append(parts, fmt.Sprintf("/img/%s %dw", name, source))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// measuredWidth is a photo's width along with the size and modification
+// time it was read at, so a replaced photo is measured again.
+type measuredWidth struct {
+	key   string
+	width int
+}
+
+var imageWidthCache struct {
+	mu     sync.Mutex
+	widths map[string]measuredWidth
+}
+
+// imageWidth returns the width of a photo, reading only its header.
+func imageWidth(name string) (int, bool) {
+	f, err := openImage(name)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return 0, false
+	}
+	key := fmt.Sprintf("%d|%d", info.Size(), info.ModTime().UnixNano())
+
+	imageWidthCache.mu.Lock()
+	defer imageWidthCache.mu.Unlock()
+	if m, ok := imageWidthCache.widths[name]; ok && m.key == key {
+		return m.width, true
+	}
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, false
+	}
+	if imageWidthCache.widths == nil {
+		imageWidthCache.widths = make(map[string]measuredWidth)
+	}
+	imageWidthCache.widths[name] = measuredWidth{key: key, width: cfg.Width}
+	return cfg.Width, true
+}
+
+// imageHandler serves the car photos under /img/. With ?w= set to one of
+// imageWidths it serves the photo scaled down to that width instead.
+func (app *App) imageHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	params, _ := matchRoute("/img/{name}", r.URL.Path)
+	name := params["name"]
+	f, err := openImage(name)
+	if err != nil {
+		app.notFoundHandler(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		app.notFoundHandler(w, r)
+		return
+	}
+
+	if contentNamedImage.MatchString(name) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+	}
+
+	widthParam := r.URL.Query().Get("w")
+	if widthParam == "" {
+		// Both files on disk and embedded ones can seek.
+		http.ServeContent(w, r, name, info.ModTime(), f.(io.ReadSeeker))
+		return
+	}
+	width, err := strconv.Atoi(widthParam)
+	if err != nil || !containsInt(imageWidths, width) {
+		w.Header().Del("Cache-Control")
+		app.renderError(w, r, errBadRequest(fmt.Sprintf("Width must be one of %s.", joinInts(imageWidths))))
+		return
+	}
+
+	key := fmt.Sprintf("%s|%d|%d|%d", name, info.Size(), info.ModTime().UnixNano(), width)
+	resized, ok := app.images.get(key)
+	if !ok {
+		resized, err = resizeImageFile(f, width)
+		if err != nil {
+			w.Header().Del("Cache-Control")
+			app.renderError(w, r, errInternal(fmt.Errorf("failed to resize %s: %w", name, err)))
+			return
+		}
+		resized.key = key
+		app.images.put(resized)
+	}
+
+	w.Header().Set("Content-Type", resized.contentType)
+	w.Header().Set("ETag", resized.etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(resized.data))
+}
+
+// resizeImageFile decodes a photo and scales it to width, keeping its
+// aspect ratio and format. Photos no wider than width are re-encoded as
+// they are; they are never scaled up.
+func resizeImageFile(f io.Reader, width int) (resizedImage, error) {
+	src, format, err := image.Decode(f)
+	if err != nil {
+		return resizedImage{}, err
+	}
+	var out image.Image = src
+	if b := src.Bounds(); b.Dx() > width {
+		dst := image.NewRGBA(image.Rect(0, 0, width, max(1, b.Dy()*width/b.Dx())))
+		resizeCover(dst, dst.Bounds(), src)
+		out = dst
+	}
+
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if format == "jpeg" {
+		contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, out, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, out)
+	}
+	if err != nil {
+		return resizedImage{}, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return resizedImage{
+		data:        buf.Bytes(),
+		contentType: contentType,
+		etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}, nil
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
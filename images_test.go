@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupImageApp(t *testing.T) *App {
+	t.Helper()
+	app := setupCatalogApp(t)
+	dir := imageDir
+	imageDir = t.TempDir()
+	t.Cleanup(func() { imageDir = dir })
+
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, testPhoto(1600, 1000), nil)
+	if err := os.WriteFile(filepath.Join(imageDir, "corolla.jpg"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func getImage(app *App, target, etag string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	rr := httptest.NewRecorder()
+	app.imageHandler(rr, req)
+	return rr
+}
+
+func TestImage_Resizes(t *testing.T) {
+	app := setupImageApp(t)
+
+	rr := getImage(app, "/img/corolla.jpg?w=320", "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("expected a jpeg, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	cfg, err := jpeg.DecodeConfig(rr.Body)
+	if err != nil || cfg.Width != 320 || cfg.Height != 200 {
+		t.Errorf("expected 320x200, got %dx%d (%v)", cfg.Width, cfg.Height, err)
+	}
+	etag := rr.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) || rr.Header().Get("Cache-Control") != "public, max-age=86400" {
+		t.Errorf("unexpected caching headers %v", rr.Header())
+	}
+
+	if rr := getImage(app, "/img/corolla.jpg?w=320", etag); rr.Code != http.StatusNotModified {
+		t.Errorf("a matching ETag should give 304, got %d", rr.Code)
+	}
+	if rr := getImage(app, "/img/corolla.jpg?w=640", ""); rr.Header().Get("ETag") == etag {
+		t.Errorf("each width needs its own ETag")
+	}
+
+	for _, target := range []string{"/img/corolla.jpg?w=500", "/img/corolla.jpg?w=big"} {
+		if rr := getImage(app, target, ""); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "320, 640, 960, 1280") {
+			t.Errorf("%s: expected 400, got %d", target, rr.Code)
+		}
+	}
+	for _, target := range []string{"/img/missing.jpg?w=320", "/img/..%2Fdata.json"} {
+		if rr := getImage(app, target, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", target, rr.Code)
+		}
+	}
+
+	if rr := getImage(app, "/img/corolla.jpg", ""); rr.Code != http.StatusOK || rr.Body.Len() == 0 {
+		t.Errorf("without a width the original should be served, got %d", rr.Code)
+	}
+}
+
+func TestImage_ContentNamedAreImmutable(t *testing.T) {
+	app := setupImageApp(t)
+	data, _ := os.ReadFile(filepath.Join(imageDir, "corolla.jpg"))
+	os.WriteFile(filepath.Join(imageDir, "0123456789abcdef.jpg"), data, 0o644)
+
+	rr := getImage(app, "/img/0123456789abcdef.jpg?w=960", "")
+	if !strings.Contains(rr.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("content-named photos should be cached for good, got %q", rr.Header().Get("Cache-Control"))
+	}
+}
+
+func TestImageCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := imageCache{limit: 10}
+	c.put(resizedImage{key: "a", data: make([]byte, 4)})
+	c.put(resizedImage{key: "b", data: make([]byte, 4)})
+	c.get("a")
+	c.put(resizedImage{key: "c", data: make([]byte, 4)})
+
+	if _, ok := c.get("b"); ok {
+		t.Errorf("the least recently used entry should have been evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Errorf("a recently read entry should stay")
+	}
+	c.put(resizedImage{key: "huge", data: make([]byte, 11)})
+	if _, ok := c.get("huge"); ok || c.size != 8 {
+		t.Errorf("entries larger than the whole cache should be skipped, size %d", c.size)
+	}
+}
+
+func TestImageSrcset(t *testing.T) {
+	app := setupCatalogApp(t)
+	rr := get(t, app.fragmentHandler, "/fragments/grid")
+	if !strings.Contains(rr.Body.String(), `srcset="/img/`) || !strings.Contains(rr.Body.String(), `?w=640 640w`) {
+		t.Errorf("grid images should offer a srcset:\n%s", rr.Body.String())
+	}
+
+	setupImageApp(t)
+	if got, want := imageSrcset("corolla.jpg"), "/img/corolla.jpg?w=320 320w, /img/corolla.jpg?w=640 640w, /img/corolla.jpg?w=960 960w, /img/corolla.jpg?w=1280 1280w, /img/corolla.jpg 1600w"; got != want {
+		t.Errorf("got srcset %q, want %q", got, want)
+	}
+	// A narrow photo is not offered at widths larger than itself.
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, testPhoto(640, 400), nil)
+	if err := os.WriteFile(filepath.Join(imageDir, "corolla.jpg"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := imageSrcset("corolla.jpg"), "/img/corolla.jpg?w=320 320w, /img/corolla.jpg 640w"; got != want {
+		t.Errorf("got srcset %q, want %q", got, want)
+	}
+}
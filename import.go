@@ -0,0 +1,668 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An import replaces the catalog's cars with those in a file, either a CSV
+// with the export's columns or JSON in the data.json shape. Every record
+// goes through the same validation as the console's forms, and the result
+// is shown as a diff before it is applied.
+//
+// CSV rows name their manufacturer and category, which must already exist.
+// JSON files may also carry manufacturers and categories; these are matched
+// to the catalog's by name, added when new and updated when they differ,
+// but never removed. Cars are matched by ID, or by name and year when the
+// ID is blank; cars the file does not mention are removed.
+
+// importRecord is one record from an import file, in the console's form
+// field names. Cars name their manufacturer and category unless the file
+// refers to the catalog's IDs directly.
+type importRecord struct {
+	where  string
+	id     int
+	values url.Values
+}
+
+type importData struct {
+	Manufacturers []importRecord
+	Categories    []importRecord
+	Cars          []importRecord
+}
+
+// importErrors lists every problem found in an import, each prefixed with
+// where in the file it was found.
+type importErrors []string
+
+func (e importErrors) Error() string {
+	return strings.Join(e, "\n")
+}
+
+// importColumns are the CSV columns an import needs; the export's other
+// columns are optional, and country is ignored.
+var importColumns = []string{"name", "year", "manufacturer", "category", "horsepower"}
+
+// parseImport reads an import file, telling JSON from CSV by its first
+// character.
+func parseImport(data []byte) (importData, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseImportJSON(data)
+	}
+	return parseImportCSV(data)
+}
+
+func parseImportCSV(data []byte) (importData, error) {
+	var in importData
+	cr := csv.NewReader(bytes.NewReader(data))
+	header, err := cr.Read()
+	if err == io.EOF {
+		return in, importErrors{"The file is empty."}
+	}
+	if err != nil {
+		return in, importErrors{err.Error()}
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	var errs importErrors
+	for _, name := range importColumns {
+		if _, ok := columns[name]; !ok {
+			errs = append(errs, fmt.Sprintf("The CSV has no %s column.", name))
+		}
+	}
+	if errs != nil {
+		return in, errs
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return in, importErrors{err.Error()}
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return record[i]
+			}
+			return ""
+		}
+		car := importRecord{where: fmt.Sprintf("Line %d", line), values: url.Values{}}
+		for _, name := range []string{"name", "year", "manufacturer", "category", "engine", "horsepower", "transmission", "drivetrain", "image"} {
+			car.values.Set(name, field(name))
+		}
+		if id := strings.TrimSpace(field("id")); id != "" {
+			if car.id, err = strconv.Atoi(id); err != nil || car.id < 1 {
+				errs = append(errs, fmt.Sprintf("%s: ID must be a positive whole number or blank.", car.where))
+				continue
+			}
+		}
+		in.Cars = append(in.Cars, car)
+	}
+	if errs != nil {
+		return in, errs
+	}
+	return in, nil
+}
+
+func parseImportJSON(data []byte) (importData, error) {
+	var in importData
+	var file catalogFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return in, importErrors{"The JSON could not be read: " + err.Error()}
+	}
+
+	manufacturers := make(map[int]string, len(file.Manufacturers))
+	for i, m := range file.Manufacturers {
+		manufacturers[m.ID] = m.Name
+		in.Manufacturers = append(in.Manufacturers, importRecord{
+			where:  fmt.Sprintf("manufacturers[%d]", i),
+			values: importPublication(url.Values{"name": {m.Name}, "country": {m.Country}, "foundingYear": {strconv.Itoa(m.Founded)}}, m.Status, m.PublishAt),
+		})
+	}
+	categories := make(map[int]string, len(file.Categories))
+	for i, category := range file.Categories {
+		categories[category.ID] = category.Name
+		in.Categories = append(in.Categories, importRecord{
+			where:  fmt.Sprintf("categories[%d]", i),
+			values: importPublication(url.Values{"name": {category.Name}}, category.Status, category.PublishAt),
+		})
+	}
+
+	var errs importErrors
+	for i, car := range file.CarModels {
+		specs := car.Specifications
+		record := importRecord{where: fmt.Sprintf("carModels[%d]", i), id: car.ID, values: url.Values{
+			"name":         {car.Name},
+			"year":         {strconv.Itoa(car.Year)},
+			"engine":       {specs.Engine},
+			"horsepower":   {strconv.Itoa(specs.Horsepower)},
+			"transmission": {specs.Transmission},
+			"drivetrain":   {specs.Drivetrain},
+			"image":        {car.Image},
+		}}
+		importPublication(record.values, car.Status, car.PublishAt)
+		// IDs refer to the file's own manufacturers and categories when it
+		// lists them, and to the catalog's otherwise.
+		if file.Manufacturers == nil {
+			record.values.Set("manufacturerId", strconv.Itoa(car.ManufacturerID))
+		} else if name, ok := manufacturers[car.ManufacturerID]; ok {
+			record.values.Set("manufacturer", name)
+		} else {
+			errs = append(errs, fmt.Sprintf("%s: manufacturerId %d is not among the file's manufacturers.", record.where, car.ManufacturerID))
+		}
+		if file.Categories == nil {
+			record.values.Set("categoryId", strconv.Itoa(car.CategoryID))
+		} else if name, ok := categories[car.CategoryID]; ok {
+			record.values.Set("category", name)
+		} else {
+			errs = append(errs, fmt.Sprintf("%s: categoryId %d is not among the file's categories.", record.where, car.CategoryID))
+		}
+		in.Cars = append(in.Cars, record)
+	}
+	if errs != nil {
+		return in, errs
+	}
+	return in, nil
+}
+
+// importPublication copies a record's status into its values when the file
+// gives one. Records without leave the catalog's status alone.
+func importPublication(values url.Values, status, publishAt string) url.Values {
+	if status != "" {
+		values.Set("status", status)
+		values.Set("publishAt", publishAt)
+	}
+	return values
+}
+
+// planImport applies an import to a copy of base and returns the result.
+// All problems are collected, so one pass reports everything to fix.
+func planImport(base catalog, in importData) (catalog, error) {
+	next := base.clone()
+	var errs importErrors
+	if len(in.Cars) == 0 {
+		return next, importErrors{"The file has no cars. An import replaces every car, so this would remove them all."}
+	}
+
+	for _, record := range in.Manufacturers {
+		id := findByName(record.values.Get("name"), len(next.Manufacturers), func(i int) (int, string) {
+			return next.Manufacturers[i].ID, next.Manufacturers[i].Name
+		})
+		if err := saveManufacturer(&next, id, record.values); err != nil {
+			errs = append(errs, record.where+": "+err.Error())
+		}
+	}
+	for _, record := range in.Categories {
+		id := findByName(record.values.Get("name"), len(next.Categories), func(i int) (int, string) {
+			return next.Categories[i].ID, next.Categories[i].Name
+		})
+		if err := saveCategory(&next, id, record.values); err != nil {
+			errs = append(errs, record.where+": "+err.Error())
+		}
+	}
+
+	existing := make(map[int]bool, len(base.CarModels))
+	nextID := 0
+	for _, car := range base.CarModels {
+		existing[car.ID] = true
+		nextID = max(nextID, car.ID)
+	}
+	for _, record := range in.Cars {
+		nextID = max(nextID, record.id)
+	}
+
+	matched := make(map[int]string)
+	for _, record := range in.Cars {
+		// Work on a copy, as references are resolved against this plan's
+		// catalog.
+		values := url.Values{}
+		for name, v := range record.values {
+			values[name] = v
+		}
+		if name := values.Get("manufacturer"); values.Get("manufacturerId") == "" {
+			id := findByName(name, len(next.Manufacturers), func(i int) (int, string) {
+				return next.Manufacturers[i].ID, next.Manufacturers[i].Name
+			})
+			if id == 0 {
+				errs = append(errs, fmt.Sprintf("%s: There is no manufacturer named %q.", record.where, name))
+				continue
+			}
+			values.Set("manufacturerId", strconv.Itoa(id))
+		}
+		if name := values.Get("category"); values.Get("categoryId") == "" {
+			id := findByName(name, len(next.Categories), func(i int) (int, string) {
+				return next.Categories[i].ID, next.Categories[i].Name
+			})
+			if id == 0 {
+				errs = append(errs, fmt.Sprintf("%s: There is no category named %q.", record.where, name))
+				continue
+			}
+			values.Set("categoryId", strconv.Itoa(id))
+		}
+
+		id := record.id
+		if id == 0 {
+			id = matchCar(base, matched, values)
+		}
+		if where, dup := matched[id]; dup && id != 0 {
+			errs = append(errs, fmt.Sprintf("%s: car %d already appears at %s.", record.where, id, where))
+			continue
+		}
+		if existing[id] {
+			matched[id] = record.where
+			if err := saveCar(&next, id, values); err != nil {
+				errs = append(errs, record.where+": "+err.Error())
+			}
+			continue
+		}
+
+		// New cars keep an ID the file gives them; the rest are numbered
+		// after every ID in use or in the file.
+		if err := saveCar(&next, 0, values); err != nil {
+			errs = append(errs, record.where+": "+err.Error())
+			continue
+		}
+		if id == 0 {
+			nextID++
+			id = nextID
+		}
+		next.CarModels[len(next.CarModels)-1].ID = id
+		matched[id] = record.where
+	}
+
+	for _, car := range base.CarModels {
+		if _, ok := matched[car.ID]; !ok {
+			deleteCar(&next, car.ID)
+		}
+	}
+	if errs != nil {
+		return base, errs
+	}
+	return next, nil
+}
+
+// findByName returns the ID of the record whose name matches, ignoring
+// case, or 0.
+func findByName(name string, n int, record func(i int) (int, string)) int {
+	name = strings.TrimSpace(name)
+	for i := 0; i < n; i++ {
+		if id, other := record(i); strings.EqualFold(other, name) {
+			return id
+		}
+	}
+	return 0
+}
+
+// matchCar finds the car a row without an ID stands for: one with the same
+// name and year that no other row has claimed.
+func matchCar(base catalog, matched map[int]string, values url.Values) int {
+	year, _ := strconv.Atoi(strings.TrimSpace(values.Get("year")))
+	name := strings.TrimSpace(values.Get("name"))
+	for _, car := range base.CarModels {
+		if _, taken := matched[car.ID]; !taken && car.Year == year && strings.EqualFold(car.Name, name) {
+			return car.ID
+		}
+	}
+	return 0
+}
+
+// recordChange is one added, changed or removed record in a catalog diff.
+// Added and removed records list every field that has a value.
+type recordChange struct {
+	Kind   string
+	ID     int
+	Name   string
+	Fields []fieldChange
+}
+
+type fieldChange struct {
+	Field string `json:"field"`
+	Label string `json:"label"`
+	Old   string `json:"before"`
+	New   string `json:"after"`
+}
+
+type catalogDiff struct {
+	Added   []recordChange
+	Changed []recordChange
+	Removed []recordChange
+}
+
+func (d catalogDiff) Empty() bool {
+	return len(d.Added)+len(d.Changed)+len(d.Removed) == 0
+}
+
+func (d catalogDiff) Summary() string {
+	return fmt.Sprintf("%d added, %d changed, %d removed.", len(d.Added), len(d.Changed), len(d.Removed))
+}
+
+// diffCatalogs compares two catalogs record by record, through the same
+// field values the console's forms show.
+func diffCatalogs(base, next catalog) catalogDiff {
+	var d catalogDiff
+	for _, kind := range []string{"manufacturers", "categories", "cars"} {
+		res := adminResources[kind]
+		labels := make(map[string]string)
+		var order []string
+		for _, field := range res.fields(next) {
+			labels[field.Name] = field.Label
+			order = append(order, field.Name)
+		}
+
+		for _, id := range recordIDs(base, next, kind) {
+			before, inBase := res.values(base, id)
+			after, inNext := res.values(next, id)
+			name := recordName(kind, after)
+			if !inNext {
+				name = recordName(kind, before)
+			}
+			change := recordChange{Kind: res.Singular, ID: id, Name: name}
+			for _, field := range order {
+				old, new := "", ""
+				if inBase {
+					old = displayValue(base, field, before.Get(field))
+				}
+				if inNext {
+					new = displayValue(next, field, after.Get(field))
+				}
+				if old != new {
+					change.Fields = append(change.Fields, fieldChange{Field: field, Label: labels[field], Old: old, New: new})
+				}
+			}
+			switch {
+			case !inBase:
+				d.Added = append(d.Added, change)
+			case !inNext:
+				d.Removed = append(d.Removed, change)
+			case change.Fields != nil:
+				d.Changed = append(d.Changed, change)
+			}
+		}
+	}
+	return d
+}
+
+func recordIDs(base, next catalog, kind string) []int {
+	seen := make(map[int]bool)
+	for _, c := range []catalog{base, next} {
+		switch kind {
+		case "manufacturers":
+			for _, m := range c.Manufacturers {
+				seen[m.ID] = true
+			}
+		case "categories":
+			for _, category := range c.Categories {
+				seen[category.ID] = true
+			}
+		case "cars":
+			for _, car := range c.CarModels {
+				seen[car.ID] = true
+			}
+		}
+	}
+	ids := make([]int, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func recordName(kind string, values url.Values) string {
+	if kind == "cars" {
+		return values.Get("name") + " " + values.Get("year")
+	}
+	return values.Get("name")
+}
+
+// displayValue shows manufacturer and category references by name.
+func displayValue(c catalog, field, value string) string {
+	id, _ := strconv.Atoi(value)
+	switch field {
+	case "manufacturerId":
+		for _, m := range c.Manufacturers {
+			if m.ID == id {
+				return m.Name
+			}
+		}
+	case "categoryId":
+		if name := c.categoryName(id); name != "" {
+			return name
+		}
+	}
+	return value
+}
+
+func (d catalogDiff) writeText(w io.Writer) {
+	for _, section := range []struct {
+		mark    string
+		changes []recordChange
+	}{{"+", d.Added}, {"~", d.Changed}, {"-", d.Removed}} {
+		for _, change := range section.changes {
+			fmt.Fprintf(w, "%s %s %d %s\n", section.mark, change.Kind, change.ID, change.Name)
+			if section.mark != "~" {
+				continue
+			}
+			for _, field := range change.Fields {
+				fmt.Fprintf(w, "    %s: %q -> %q\n", field.Label, field.Old, field.New)
+			}
+		}
+	}
+	fmt.Fprintln(w, d.Summary())
+}
+
+// importDigest ties a preview to the catalog it was made against and the
+// data it showed, so applying it cannot land on another catalog or bring
+// other data along. Line endings do not count, since browsers send the
+// previewed data back with CRLF.
+func importDigest(c catalog, data []byte) string {
+	encoded, _ := encodeCatalogFile(c)
+	catalogSum := sha256.Sum256(encoded)
+	h := sha256.New()
+	h.Write(catalogSum[:])
+	h.Write(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// maxImportBytes caps what the console imports. It is well below the body
+// limit adminHandler sets, because the preview posts the data back as an
+// escaped form field to apply it.
+const maxImportBytes = 2 << 20
+
+// importHandler serves /admin/import. Posting a file previews the import;
+// posting the previewed data back with its digest applies it.
+func (app *App) importHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		app.renderImport(w, r, http.StatusOK, importPreview{})
+		return
+	}
+
+	var data []byte
+	if file, _, err := r.FormFile("file"); err == nil {
+		defer file.Close()
+		if data, err = io.ReadAll(io.LimitReader(file, maxImportBytes+1)); err != nil {
+			app.renderError(w, r, errBadRequest("The file could not be read."))
+			return
+		}
+	} else {
+		data = []byte(r.PostForm.Get("data"))
+	}
+	if len(data) > maxImportBytes {
+		app.renderImport(w, r, http.StatusRequestEntityTooLarge, importPreview{Errors: []string{
+			fmt.Sprintf("The file is larger than %d MB. Split it, or import it with the import command.", maxImportBytes>>20),
+		}})
+		return
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		app.renderImport(w, r, http.StatusUnprocessableEntity, importPreview{Errors: []string{"Choose a CSV or JSON file to import."}})
+		return
+	}
+	in, err := parseImport(data)
+	if err != nil {
+		app.renderImport(w, r, http.StatusUnprocessableEntity, importPreview{Errors: errorLines(err)})
+		return
+	}
+
+	stale := false
+	if r.PostForm.Get("apply") != "" {
+		digest := r.PostForm.Get("digest")
+		err = app.store.update(sessionFrom(r).Username, func(c *catalog) error {
+			if importDigest(*c, data) != digest {
+				stale = true
+				return validationError{"": "The catalog or the data changed after this preview was made."}
+			}
+			next, err := planImport(*c, in)
+			*c = next
+			return err
+		})
+		var problems importErrors
+		if err == nil {
+			http.Redirect(w, r, "/admin#cars", http.StatusSeeOther)
+			return
+		}
+		if !stale && !errors.As(err, &problems) {
+			app.renderError(w, r, err)
+			return
+		}
+	}
+
+	// Preview against the current catalog, which after a failed apply
+	// shows what has changed since.
+	base := app.store.catalog()
+	preview := importPreview{Data: string(data), Digest: importDigest(base, data)}
+	next, err := planImport(base, in)
+	status := http.StatusOK
+	if err != nil {
+		status, preview.Errors = http.StatusUnprocessableEntity, errorLines(err)
+	} else {
+		diff := diffCatalogs(base, next)
+		preview.Diff = &diff
+		if stale {
+			status = http.StatusConflict
+			preview.Notice = "The catalog or the data changed after the last preview. Review the changes below again before applying them."
+		}
+	}
+	app.renderImport(w, r, status, preview)
+}
+
+// importPreview is what the import page shows: the file's problems, or the
+// diff it would make along with what is needed to apply it.
+type importPreview struct {
+	Errors []string
+	Notice string
+	Diff   *catalogDiff
+	Data   string
+	Digest string
+}
+
+func errorLines(err error) []string {
+	var problems importErrors
+	if errors.As(err, &problems) {
+		return problems
+	}
+	return []string{err.Error()}
+}
+
+func (app *App) renderImport(w http.ResponseWriter, r *http.Request, status int, preview importPreview) {
+	page := struct {
+		adminPage
+		importPreview
+	}{app.adminPage(r, "Import - Aurora Cars"), preview}
+	app.renderStatus(w, r, status, "admin_import.html", page)
+}
+
+// runImport implements the "import" subcommand, which previews an import
+// against a data.json file and, with -apply, writes the result:
+//
+//	cars import new-models.csv
+//	cars import -apply new-models.csv
+//
+// A file name of "-" reads standard input. Stop the server first if its
+// admin console uses the same file, or it will not see the change.
+func runImport(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	dataPath := fs.String("data", "api/data.json", "catalog file in the Cars API data.json format")
+	apply := fs.Bool("apply", false, "write the changes instead of only showing them")
+	auditPath := fs.String("audit", "admin_audit.jsonl", "audit log to record the changes in; empty for none")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: import [-data file] [-audit file] [-apply] file.csv|file.json|-")
+	}
+
+	var data []byte
+	var err error
+	if fs.Arg(0) == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	in, err := parseImport(data)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore(*dataPath, nil)
+	if err != nil {
+		return err
+	}
+	base := store.catalog()
+	next, err := planImport(base, in)
+	if err != nil {
+		return err
+	}
+	diff := diffCatalogs(base, next)
+	diff.writeText(stdout)
+	if !*apply || diff.Empty() {
+		if !diff.Empty() {
+			fmt.Fprintln(stdout, "Dry run; use -apply to write these changes.")
+		}
+		return nil
+	}
+	if *auditPath != "" {
+		store.audit = &auditLog{path: *auditPath}
+	}
+	err = store.update(commandLineActor(), func(c *catalog) error {
+		*c = next
+		return nil
+	})
+	if err == nil {
+		fmt.Fprintf(stdout, "Wrote %s.\n", *dataPath)
+	}
+	return err
+}
+
+// commandLineActor names whoever runs a subcommand in the audit log.
+func commandLineActor() string {
+	if u, err := user.Current(); err == nil {
+		return "command line (" + u.Username + ")"
+	}
+	return "command line"
+}
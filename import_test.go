@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// importCSV keeps car 1 with more horsepower, matches car 3 by name and
+// year, adds a Camry and leaves out car 2.
+const importCSV = "\ufeffid,name,year,manufacturer,country,category,engine,horsepower,transmission,drivetrain,image\n" +
+	"1,Mercedes-Benz GLE,2022,Mercedes-Benz,Germany,SUV,,375,,,\n" +
+	",Toyota Corolla,2023,toyota,Japan,sedan,,139,,,\n" +
+	",Toyota Camry,2025,Toyota,Japan,Sedan,2.5L Inline-4,225,,,\n"
+
+func TestImport_PreviewAndApply(t *testing.T) {
+	app, path := setupAdminApp(t)
+	before, _ := os.ReadFile(path)
+
+	rr := postFileAs(t, app, "eddie", "/admin/import", "file", []byte(importCSV), "")
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, "1 added, 1 changed, 1 removed.") {
+		t.Fatalf("expected a preview, got %d:\n%s", rr.Code, body)
+	}
+	for _, want := range []string{"car 4: Toyota Camry 2025", "Horsepower: <del>362</del> <ins>375</ins>", "car 2: Mercedes-Benz E-Class 2023"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("preview is missing %q", want)
+		}
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Fatalf("a preview must not change the catalog")
+	}
+
+	digest := importDigest(app.store.catalog(), []byte(importCSV))
+	tampered := url.Values{"data": {strings.Replace(importCSV, "375", "999", 1)}, "digest": {digest}, "apply": {"1"}}
+	if rr := adminRequest(app, "POST", "/admin/import", tampered); rr.Code != http.StatusConflict {
+		t.Fatalf("data other than the previewed data should not be applied, got %d", rr.Code)
+	}
+	apply := func() url.Values {
+		// Browsers send the previewed data back with CRLF line endings.
+		return url.Values{"data": {strings.ReplaceAll(importCSV, "\n", "\r\n")}, "digest": {digest}, "apply": {"1"}}
+	}
+	if rr := adminRequest(app, "POST", "/admin/import", apply()); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected the import to apply, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+	c := app.snapshot()
+	if car, _ := c.findCar(2); car != nil {
+		t.Errorf("car 2 should have been removed")
+	}
+	if car, _ := c.findCar(1); car == nil || car.Specifications.Horsepower != 375 {
+		t.Errorf("car 1 should have been updated, got %+v", car)
+	}
+	if car, _ := c.findCar(4); car == nil || car.Name != "Toyota Camry" || car.ManufacturerName != "Toyota" || car.CategoryID != 2 {
+		t.Errorf("the Camry should have been added as car 4, got %+v", car)
+	}
+	saved, _ := loadCatalogFile(path)
+	if len(saved.CarModels) != 3 {
+		t.Errorf("the file should hold the imported cars, got %d", len(saved.CarModels))
+	}
+
+	// Applying the same preview again finds the catalog changed.
+	rr = adminRequest(app, "POST", "/admin/import", apply())
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "The catalog or the data changed after the last preview.") {
+		t.Errorf("a stale preview should not be applied, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "0 added, 0 changed, 0 removed.") {
+		t.Errorf("the fresh preview should show there is nothing left to do")
+	}
+}
+
+func TestImport_Problems(t *testing.T) {
+	app, path := setupAdminApp(t)
+	before, _ := os.ReadFile(path)
+
+	csv := "id,name,year,manufacturer,category,horsepower\n" +
+		"1,Mercedes-Benz GLE,1700,Mercedes-Benz,SUV,362\n" +
+		",Honda Civic,2024,Honda,Sedan,180\n" +
+		"3,Toyota Corolla,2023,Toyota,Sedan,139\n" +
+		"3,Toyota Corolla,2024,Toyota,Sedan,139\n" +
+		"x,Toyota Yaris,2024,Toyota,Sedan,120\n"
+	rr := postFileAs(t, app, "eddie", "/admin/import", "file", []byte(csv), "")
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "Line 6: ID must be a positive whole number or blank.") {
+		t.Fatalf("bad IDs should be reported first, got %d", rr.Code)
+	}
+
+	csv = strings.Replace(csv, "x,Toyota Yaris", ",Toyota Yaris", 1)
+	rr = postFileAs(t, app, "eddie", "/admin/import", "file", []byte(csv), "")
+	body := rr.Body.String()
+	for _, want := range []string{
+		"Line 2: Year must be a whole number from 1886",
+		"Line 3: There is no manufacturer named &#34;Honda&#34;.",
+		"Line 5: car 3 already appears at Line 4.",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "Apply these changes") {
+		t.Errorf("a file with problems must not be offered for applying")
+	}
+
+	rr = postFileAs(t, app, "eddie", "/admin/import", "file", []byte("name,year\nFoo,2024\n"), "")
+	if !strings.Contains(rr.Body.String(), "The CSV has no manufacturer column.") {
+		t.Errorf("missing columns should be reported, got %d", rr.Code)
+	}
+	if rr := postFileAs(t, app, "vera", "/admin/import", "file", []byte(importCSV), ""); rr.Code != http.StatusForbidden {
+		t.Errorf("viewers must not import, got %d", rr.Code)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Errorf("rejected imports must leave the catalog alone")
+	}
+}
+
+func TestImport_TooLarge(t *testing.T) {
+	app, _ := setupAdminApp(t)
+	data := []byte(importCSV + strings.Repeat(",Toyota Camry,2025,Toyota,Japan,Sedan,,,,,\n", maxImportBytes/40))
+
+	rr := postFileAs(t, app, "eddie", "/admin/import", "file", data, "")
+	if rr.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rr.Body.String(), "larger than 2 MB") {
+		t.Errorf("expected 413 with the size limit, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+	if rr := postFileAs(t, app, "eddie", "/admin/import", "file", make([]byte, maxUploadBytes+128<<10), ""); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("a body over the console limit: expected 413, got %d", rr.Code)
+	}
+}
+
+func TestPlanImport_JSON(t *testing.T) {
+	app := setupCatalogApp(t)
+	base := app.snapshot()
+	data := `{
+	  "manufacturers": [
+	    {"id": 10, "name": "Toyota", "country": "Japan", "foundingYear": 1937},
+	    {"id": 11, "name": "Honda", "country": "Japan", "foundingYear": 1948}
+	  ],
+	  "categories": [{"id": 5, "name": "Sedan"}, {"id": 6, "name": "Hatchback"}],
+	  "carModels": [
+	    {"id": 3, "name": "Toyota Corolla", "manufacturerId": 10, "categoryId": 5, "year": 2023, "specifications": {"horsepower": 139}},
+	    {"id": 20, "name": "Honda Civic", "manufacturerId": 11, "categoryId": 6, "year": 2024, "specifications": {"horsepower": 180}}
+	  ]
+	}`
+	in, err := parseImport([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := planImport(base, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	civic, honda := next.findCar(20)
+	if civic == nil || honda.Name != "Honda" || honda.ID != 3 || next.categoryName(civic.CategoryID) != "Hatchback" {
+		t.Fatalf("the Civic should keep its ID and point at the new manufacturer and category, got %+v %+v", civic, honda)
+	}
+	diff := diffCatalogs(base, next)
+	if diff.Summary() != "3 added, 0 changed, 2 removed." {
+		t.Errorf("unexpected diff %s", diff.Summary())
+	}
+	var text bytes.Buffer
+	diff.writeText(&text)
+	for _, want := range []string{"+ manufacturer 3 Honda", "+ category 3 Hatchback", "+ car 20 Honda Civic 2024", "- car 1 Mercedes-Benz GLE 2022"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text diff is missing %q:\n%s", want, text.String())
+		}
+	}
+}
+
+func TestRunImport(t *testing.T) {
+	_, path := setupAdminApp(t)
+	file := filepath.Join(t.TempDir(), "cars.csv")
+	os.WriteFile(file, []byte(importCSV), 0o644)
+	before, _ := os.ReadFile(path)
+
+	var out bytes.Buffer
+	if err := runImport([]string{"-data", path, file}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "~ car 1 Mercedes-Benz GLE 2022\n    Horsepower: \"362\" -> \"375\"") || !strings.Contains(out.String(), "Dry run") {
+		t.Errorf("unexpected dry run output:\n%s", out.String())
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Fatalf("a dry run must not write")
+	}
+
+	out.Reset()
+	audit := &auditLog{path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	if err := runImport([]string{"-data", path, "-audit", audit.path, "-apply", "-"}, strings.NewReader(importCSV), &out); err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := loadCatalogFile(path)
+	if len(saved.CarModels) != 3 || saved.CarModels[2].Name != "Toyota Camry" {
+		t.Errorf("-apply should write the import, got %+v", saved.CarModels)
+	}
+	entries, _ := audit.read(func(auditEntry) bool { return true })
+	if len(entries) != 3 || !strings.HasPrefix(entries[0].Actor, "command line") {
+		t.Errorf("-apply should be audited, got %+v", entries)
+	}
+}
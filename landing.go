@@ -0,0 +1,138 @@
+package main
+
+import (
+	"cars/structs"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+func categoryURL(name string) string {
+	return "/categories/" + slugify(name)
+}
+
+func countryURL(name string) string {
+	return "/countries/" + slugify(name)
+}
+
+type landingPage struct {
+	Title     string
+	Kind      string
+	Name      string
+	CarModels []structs.CarModel
+	Stats     structs.LineupStats
+	Breakdown []structs.ManufacturerShare
+}
+
+// categoryHandler serves /category?id=8 and /categories/electric.
+func (app *App) categoryHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	key := r.URL.Query().Get("id")
+	if params, ok := matchRoute("/categories/{slug}", r.URL.Path); ok {
+		key = params["slug"]
+	}
+
+	c := app.snapshot()
+	category := c.findCategory(key)
+	if category == nil {
+		app.renderError(w, r, errNotFound("Category not found."))
+		return
+	}
+
+	var lineup []structs.CarModel
+	for _, car := range c.CarModels {
+		if car.CategoryID == category.ID {
+			lineup = append(lineup, car)
+		}
+	}
+
+	app.renderLanding(w, r, c, "Category", category.Name, lineup)
+}
+
+// countryHandler serves /country?name=Japan and /countries/japan.
+func (app *App) countryHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	key := slugify(r.URL.Query().Get("name"))
+	if params, ok := matchRoute("/countries/{slug}", r.URL.Path); ok {
+		key = params["slug"]
+	}
+
+	c := app.snapshot()
+	country := app.findCountry(c.Manufacturers, key)
+	if country == "" {
+		app.renderError(w, r, errNotFound("Country not found."))
+		return
+	}
+
+	var lineup []structs.CarModel
+	for _, car := range c.CarModels {
+		if c.isCarFromCountry(car, country) {
+			lineup = append(lineup, car)
+		}
+	}
+
+	app.renderLanding(w, r, c, "Country", country, lineup)
+}
+
+func (app *App) renderLanding(w http.ResponseWriter, r *http.Request, c catalog, kind, name string, lineup []structs.CarModel) {
+	data := landingPage{
+		Title:     name + " - Aurora Cars",
+		Kind:      kind,
+		Name:      name,
+		CarModels: lineup,
+		Stats:     c.lineupStats(lineup),
+		Breakdown: c.manufacturerBreakdown(lineup),
+	}
+
+	app.render(w, r, "landing.html", data)
+}
+
+func (c catalog) findCategory(key string) *structs.Category {
+	if key == "" {
+		return nil
+	}
+	id, err := strconv.Atoi(key)
+	for i, category := range c.Categories {
+		if (err == nil && category.ID == id) || slugify(category.Name) == key {
+			return &c.Categories[i]
+		}
+	}
+	return nil
+}
+
+func (app *App) findCountry(manufacturers []structs.Manufacturer, slug string) string {
+	if slug == "" {
+		return ""
+	}
+	for _, country := range app.getUniqueCountries(manufacturers) {
+		if slugify(country) == slug {
+			return country
+		}
+	}
+	return ""
+}
+
+// manufacturerBreakdown counts cars per manufacturer, largest share first.
+func (c catalog) manufacturerBreakdown(cars []structs.CarModel) []structs.ManufacturerShare {
+	counts := make(map[int]int)
+	for _, car := range cars {
+		counts[car.ManufacturerID]++
+	}
+
+	var breakdown []structs.ManufacturerShare
+	for _, m := range c.Manufacturers {
+		if counts[m.ID] > 0 {
+			breakdown = append(breakdown, structs.ManufacturerShare{Manufacturer: m, ModelCount: counts[m.ID]})
+		}
+	}
+	sort.SliceStable(breakdown, func(i, j int) bool {
+		return breakdown[i].ModelCount > breakdown[j].ModelCount
+	})
+	return breakdown
+}
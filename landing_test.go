@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCategoryHandler(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	for _, url := range []string{"/category?id=2", "/categories/sedan", "/categories/2"} {
+		req, rr := httptest.NewRequest("GET", url, nil), httptest.NewRecorder()
+		app.categoryHandler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %v", url, rr.Code)
+		}
+		body := rr.Body.String()
+		for _, want := range []string{"Mercedes-Benz E-Class", "Toyota Corolla", "Average horsepower: 197 hp", `href="/manufacturers/toyota"`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: expected body to contain %q", url, want)
+			}
+		}
+		if strings.Contains(body, "Mercedes-Benz GLE") {
+			t.Errorf("%s: lineup contains a model from another category", url)
+		}
+	}
+}
+
+func TestCountryHandler(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	for _, url := range []string{"/country?name=Germany", "/countries/germany"} {
+		req, rr := httptest.NewRequest("GET", url, nil), httptest.NewRecorder()
+		app.countryHandler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %v", url, rr.Code)
+		}
+		body := rr.Body.String()
+		if !strings.Contains(body, "Mercedes-Benz GLE") || strings.Contains(body, "Toyota Corolla") {
+			t.Errorf("%s: unexpected lineup: %s", url, body)
+		}
+		if !strings.Contains(body, "2 models") {
+			t.Errorf("%s: expected manufacturer breakdown with 2 models", url)
+		}
+	}
+}
+
+func TestLandingHandlers_NotFound(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	tests := []struct {
+		url     string
+		handler http.HandlerFunc
+	}{
+		{"/category?id=42", app.categoryHandler},
+		{"/categories/minivan", app.categoryHandler},
+		{"/country?name=Atlantis", app.countryHandler},
+		{"/countries/", app.countryHandler},
+	}
+	for _, tt := range tests {
+		req, rr := httptest.NewRequest("GET", tt.url, nil), httptest.NewRecorder()
+		tt.handler(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %v", tt.url, rr.Code)
+		}
+	}
+}
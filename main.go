@@ -2,13 +2,15 @@ package main
 
 import (
 	"cars/structs"
-	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"sort"
 	"strconv"
@@ -18,10 +20,28 @@ import (
 )
 
 type App struct {
-	templates     *template.Template
-	manufacturers []structs.Manufacturer
-	carModels     []structs.CarModel
-	categories    []structs.Category
+	templates   *template.Template
+	assets      *assetSet
+	config      Config
+	routes      []string
+	muxPrefixes map[string]bool
+	// mu guards catalog and arrivals. Handlers read the catalog through
+	// snapshot, once per request.
+	mu       sync.RWMutex
+	catalog  catalog
+	arrivals []arrival
+	ogImages ogImageCache
+	images   imageCache
+	// store is set while the admin console is enabled, and is then the
+	// catalog's source instead of the API.
+	store      *catalogStore
+	accounts   *accountStore
+	sessionKey []byte
+	logins     loginLimiter
+	// dev is set in development mode and then supplies the templates.
+	dev *devTemplates
+	// publishTimer fires when the next scheduled record is due.
+	publishTimer *time.Timer
 }
 
 func contains(slice []string, value string) bool {
@@ -33,27 +53,120 @@ func contains(slice []string, value string) bool {
 	return false
 }
 
-func main() {
+func parseTemplates(fsys fs.FS, assets *assetSet) (*template.Template, error) {
 	funcMap := template.FuncMap{
-		"contains": contains,
+		"asset":           assets.url,
+		"contains":        contains,
+		"carURL":          carURL,
+		"manufacturerURL": manufacturerURL,
+		"categoryURL":     categoryURL,
+		"countryURL":      countryURL,
+		"imageSrcset":     imageSrcset,
 	}
+	return template.New("").Funcs(funcMap).ParseFS(fsys, "*.html")
+}
 
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "user" {
+		if err := runUser(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("User command failed: %v", err)
+		}
+		return
+	}
+
+	configPath := flag.String("config", "config.json", "path to the JSON config file")
+	templatesDir := flag.String("templates", "", "serve templates from this directory instead of the built-in ones")
+	staticDir := flag.String("static", "", "serve static files from this directory instead of the built-in ones")
+	dev := flag.Bool("dev", false, "development mode: reload templates from disk when they change")
+	flag.StringVar(&imageDir, "images", imageDir, "directory for uploaded car photos, checked before the built-in ones")
+	flag.Parse()
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	assets, err := loadAssets(bundledFS("static", *staticDir))
+	if err != nil {
+		log.Fatalf("Failed to load static assets: %v", err)
+	}
 	app := &App{
-		templates: template.Must(template.New("").Funcs(funcMap).ParseGlob("templates/*.html")),
+		assets: assets,
+		config: cfg,
+	}
+	if *dev {
+		dir := *templatesDir
+		if dir == "" {
+			dir = "templates"
+		}
+		if app.dev, err = newDevTemplates(dir, assets); err != nil {
+			log.Fatalf("Failed to parse templates: %v", err)
+		}
+		go app.dev.watch(500 * time.Millisecond)
+		log.Printf("Development mode: templates in %s reload when they change", dir)
+	} else {
+		app.templates = template.Must(parseTemplates(bundledFS("templates", *templatesDir), assets))
+	}
+	if cfg.Admin.Enabled {
+		if err := app.enableAdmin(cfg.Admin); err != nil {
+			log.Fatalf("Failed to start the admin console: %v", err)
+		}
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", app.indexHandler)
-	mux.HandleFunc("/error", app.errorHandler)
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
-	mux.Handle("/img/", http.StripPrefix("/img/", http.FileServer(http.Dir("api/img"))))
-	mux.HandleFunc("/car", app.CarDetailsHandler)
-	mux.HandleFunc("/favicon.png", app.faviconHandler)
-	mux.HandleFunc("/notfound", app.notFoundHandler)
-	mux.HandleFunc("/health", app.healthCheckHandler)
-	mux.HandleFunc("/filter", app.filterHandler)
-	mux.HandleFunc("/search", app.searchHandler)
-	mux.HandleFunc("/compare", app.compareHandler)
+	app.handleFunc(mux, "/", app.indexHandler)
+	app.handleFunc(mux, "/error", app.errorHandler)
+	app.handleFunc(mux, "/static/*", app.staticHandler)
+	app.handleFunc(mux, "/img/{name}", app.imageHandler)
+	app.handleFunc(mux, "/car", app.legacyCarHandler)
+	app.handleFunc(mux, "/cars/{id}", app.CarDetailsHandler)
+	app.handleFunc(mux, "/cars/{id}/{slug}", app.CarDetailsHandler)
+	app.handleFunc(mux, "/favicon.png", app.faviconHandler)
+	app.handleFunc(mux, "/notfound", app.notFoundHandler)
+	app.handleFunc(mux, "/health", app.healthCheckHandler)
+	app.handleFunc(mux, "/filter", app.filterHandler)
+	app.handleFunc(mux, "/search", app.searchHandler)
+	app.handleFunc(mux, "/fragments/{name}", app.fragmentHandler)
+	app.handleFunc(mux, "/compare", app.compareHandler)
+	app.handleFunc(mux, "/manufacturer", app.manufacturerHandler)
+	app.handleFunc(mux, "/manufacturers/{slug}", app.manufacturerHandler)
+	app.handleFunc(mux, "/category", app.categoryHandler)
+	app.handleFunc(mux, "/categories/{slug}", app.categoryHandler)
+	app.handleFunc(mux, "/country", app.countryHandler)
+	app.handleFunc(mux, "/countries/{slug}", app.countryHandler)
+	app.registerAPI(mux)
+	app.handleFunc(mux, "/graphql", app.graphQLHandler)
+	app.handleFunc(mux, "/export/catalog.ndjson", app.exportNDJSONHandler)
+	app.handleFunc(mux, "/export/catalog.csv", app.exportCSVHandler)
+	app.handleFunc(mux, "/sitemap.xml", app.sitemapHandler)
+	app.handleFunc(mux, "/robots.txt", app.robotsHandler)
+	app.handleFunc(mux, "/feed.atom", app.feedHandler)
+	app.handleFunc(mux, "/og/car/{file}", app.ogImageHandler)
+	app.handleFunc(mux, "/embed/car/{id}", app.embedHandler)
+	app.handleFunc(mux, "/oembed", app.oEmbedHandler)
+	app.handleFunc(mux, "/admin", app.adminHandler)
+	app.handleFunc(mux, "/admin/login", app.adminHandler)
+	app.handleFunc(mux, "/admin/logout", app.adminHandler)
+	app.handleFunc(mux, "/admin/users", app.adminHandler)
+	app.handleFunc(mux, "/admin/import", app.adminHandler)
+	app.handleFunc(mux, "/admin/audit", app.adminHandler)
+	app.handleFunc(mux, "/admin/audit.json", app.adminHandler)
+	app.handleFunc(mux, "/admin/{kind}/new", app.adminHandler)
+	app.handleFunc(mux, "/admin/{kind}/{id}", app.adminHandler)
+	app.handleFunc(mux, "/admin/{kind}/{id}/delete", app.adminHandler)
+	app.handleFunc(mux, "/admin/cars/{id}/image", app.adminHandler)
 
 	app.loadData()
 
@@ -64,8 +177,7 @@ func main() {
 }
 
 func (app *App) indexHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !app.allowMethods(w, r, http.MethodGet) {
 		return
 	}
 
@@ -75,23 +187,23 @@ func (app *App) indexHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := app.loadData(); err != nil {
-		log.Printf("Failed to load data: %v", err)
-		app.renderError(w, http.StatusInternalServerError, "Could not connect to the API server. Please try again later.")
+		app.renderError(w, r, errUnavailable("Could not connect to the API server. Please try again later.", err))
 		return
 	}
 
+	c := app.snapshot()
 	manufacturersMap := make(map[string]string)
-	for _, manufacturer := range app.manufacturers {
+	for _, manufacturer := range c.Manufacturers {
 		manufacturersMap[strconv.Itoa(manufacturer.ID)] = manufacturer.Name
 	}
 
 	data := structs.PageData{
 		Title:                 "Aurora cars",
-		Manufacturers:         app.manufacturers,
-		CarModels:             app.carModels,
-		Categories:            app.categories,
-		Countries:             app.getUniqueCountries(app.manufacturers),
-		Years:                 app.getUniqueYears(app.carModels),
+		Manufacturers:         c.Manufacturers,
+		CarModels:             c.CarModels,
+		Categories:            c.Categories,
+		Countries:             app.getUniqueCountries(c.Manufacturers),
+		Years:                 app.getUniqueYears(c.CarModels),
 		SelectedManufacturers: []string{},
 		SelectedCategories:    []string{},
 		SelectedYears:         []string{},
@@ -99,16 +211,13 @@ func (app *App) indexHandler(w http.ResponseWriter, r *http.Request) {
 		ManufacturersMap:      manufacturersMap,
 	}
 
-	if err := app.templates.ExecuteTemplate(w, "layout.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	app.respond(w, r, "layout.html", data)
 }
+
 func (app *App) checkAPIHealth() {
 	ticker := time.NewTicker(5 * time.Minute)
 	for range ticker.C {
-		_, err := http.Get("http://localhost:3000/health")
+		_, err := http.Get(app.config.APIURL + "/health")
 		if err != nil {
 			log.Println("API server is down:", err)
 		} else {
@@ -117,48 +226,75 @@ func (app *App) checkAPIHealth() {
 	}
 }
 
+// CarDetailsHandler serves /cars/{id}/{slug}. A missing or outdated slug is
+// redirected to the canonical URL so every car has exactly one address.
 func (app *App) CarDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
 	carIDStr := r.URL.Query().Get("id")
+	params, ok := matchRoute("/cars/{id}/{slug}", r.URL.Path)
+	if !ok {
+		params, ok = matchRoute("/cars/{id}", r.URL.Path)
+	}
+	if ok {
+		carIDStr = params["id"]
+	}
 	carID, err := strconv.Atoi(carIDStr)
 
 	if err != nil {
-		log.Printf("Error parsing car ID '%s': %v", carIDStr, err)
-		http.Error(w, "Invalid car ID format: %s", http.StatusInternalServerError)
+		app.renderError(w, r, errBadRequest(fmt.Sprintf("Invalid car ID %q. Car IDs are whole numbers.", carIDStr)))
 		return
 	}
 
-	var car *structs.CarModel
-	var manData *structs.Manufacturer
-	for _, c := range app.carModels {
-		if c.ID == carID {
-			car = &c
-			for _, m := range app.manufacturers {
-				if car.ManufacturerID == m.ID {
-					manData = &m
-					break
-				}
-			}
-			break
-		}
+	data, err := app.carDetails(app.linkBase(r), carID)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
 	}
 
-	if car == nil || manData == nil {
-		http.Error(w, "Car or Manufacturer not found", http.StatusNotFound)
+	if ok && r.URL.Path != carURL(*data.Car) {
+		http.Redirect(w, r, carURL(*data.Car), http.StatusMovedPermanently)
 		return
 	}
 
-	data := struct {
-		Car     *structs.CarModel
-		ManData *structs.Manufacturer
-	}{
+	app.respond(w, r, "car.html", data)
+}
+
+// carDetails is what a car page shows. The page itself, its embeddable card
+// and its oEmbed response are all built from it.
+type carDetails struct {
+	Car     *structs.CarModel     `json:"car"`
+	ManData *structs.Manufacturer `json:"manufacturer"`
+	Similar []structs.CarModel    `json:"similar"`
+	Meta    pageMeta              `json:"-"`
+}
+
+func (app *App) carDetails(base string, carID int) (carDetails, error) {
+	c := app.snapshot()
+	car, manData := c.findCar(carID)
+	if car == nil || manData == nil {
+		return carDetails{}, errNotFound("Car or manufacturer not found.")
+	}
+	return carDetails{
 		Car:     car,
 		ManData: manData,
-	}
+		Similar: c.similarCars(*car, app.config.Recommendations.Count, app.config.Recommendations.Weights),
+		Meta:    carMeta(base, *car, *manData, c.categoryName(car.CategoryID)),
+	}, nil
+}
 
-	if err := app.templates.ExecuteTemplate(w, "car.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+// legacyCarHandler keeps old /car?id= links working by redirecting them to
+// the canonical slug URL.
+func (app *App) legacyCarHandler(w http.ResponseWriter, r *http.Request) {
+	if carID, err := strconv.Atoi(r.URL.Query().Get("id")); err == nil {
+		if car, _ := app.snapshot().findCar(carID); car != nil {
+			http.Redirect(w, r, carURL(*car), http.StatusMovedPermanently)
+			return
+		}
 	}
+	app.CarDetailsHandler(w, r)
 }
 
 func (app *App) errorHandlerMiddleware(next http.Handler) http.Handler {
@@ -201,17 +337,16 @@ func fetchDataWithTimeout(url string) ([]byte, error) {
 }
 
 func (app *App) errorHandler(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "500 - Internal Server Error. We're sorry, but something went wrong. Please try again later.", http.StatusInternalServerError)
+	app.renderError(w, r, errInternal(nil))
 }
 
 func (app *App) notFoundHandler(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "404 - Page Not Found. The page you are looking for does not exist.", http.StatusNotFound)
+	app.renderError(w, r, errNotFound("The page you are looking for does not exist."))
 }
 
 func (app *App) catchAllHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-		if path != "/" && path != "/favicon.png" && !strings.HasPrefix(path, "/static/") && !strings.HasPrefix(path, "/img/") && path != "/error" && path != "/notfound" && path != "/car" && path != "/filter" && path != "/search"  && path != "/compare" {
+		if !app.isKnownRoute(r.URL.Path) {
 			app.notFoundHandler(w, r)
 			return
 		}
@@ -220,12 +355,7 @@ func (app *App) catchAllHandler(next http.Handler) http.Handler {
 }
 
 func (app *App) faviconHandler(w http.ResponseWriter, r *http.Request) {
-	if _, err := os.Stat("static/favicon.png"); os.IsNotExist(err) {
-		log.Printf("favicon.png not found: %v", err)
-		http.NotFound(w, r)
-		return
-	}
-	http.ServeFile(w, r, "static/favicon.png")
+	app.serveAsset(w, r, "favicon.png")
 }
 
 func (app *App) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -251,27 +381,33 @@ func (app *App) loadDataPeriodically() {
 }
 
 func (app *App) loadData() error {
+	// The store publishes every change itself, in the order they were
+	// written; setting its catalog here could undo a newer one.
+	if app.store != nil {
+		return nil
+	}
 	client := &http.Client{Timeout: 10 * time.Second}
 	errorsChan := make(chan error, 3)
+	var next catalog
 
 	var wg sync.WaitGroup
 	wg.Add(3)
 
 	go func() {
 		defer wg.Done()
-		err := app.fetchData("http://localhost:3000/api/manufacturers", &app.manufacturers, client)
+		err := app.fetchData(app.config.APIURL+"/api/manufacturers", &next.Manufacturers, client)
 		errorsChan <- err
 	}()
 
 	go func() {
 		defer wg.Done()
-		err := app.fetchData("http://localhost:3000/api/models", &app.carModels, client)
+		err := app.fetchData(app.config.APIURL+"/api/models", &next.CarModels, client)
 		errorsChan <- err
 	}()
 
 	go func() {
 		defer wg.Done()
-		err := app.fetchData("http://localhost:3000/api/categories", &app.categories, client)
+		err := app.fetchData(app.config.APIURL+"/api/categories", &next.Categories, client)
 		errorsChan <- err
 	}()
 
@@ -286,6 +422,8 @@ func (app *App) loadData() error {
 		}
 	}
 
+	next.LoadedAt = time.Now()
+	app.setCatalog(next)
 	log.Println("Data loaded successfully from all APIs")
 	return nil
 }
@@ -321,110 +459,6 @@ func fetchData(url string) ([]byte, error) {
 	defer resp.Body.Close()
 	return io.ReadAll(resp.Body)
 }
-func (app *App) renderError(w http.ResponseWriter, statusCode int, message string) {
-	w.WriteHeader(statusCode)
-	data := struct {
-		Title        string
-		ErrorMessage string
-	}{
-		Title:        "Error - Aurora Cars",
-		ErrorMessage: message,
-	}
-	err := app.templates.ExecuteTemplate(w, "layout.html", data)
-	if err != nil {
-		log.Printf("Error executing template for error: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-	}
-}
-
-func (app *App) loadManufacturers(wg *sync.WaitGroup, client *http.Client, ch chan error) {
-	defer wg.Done()
-	start := time.Now()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:3000/api/manufacturers", nil)
-	if err != nil {
-		ch <- err
-		return
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		ch <- err
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		ch <- err
-		return
-	}
-
-	if err := json.Unmarshal(body, &app.manufacturers); err != nil {
-		ch <- err
-		return
-	}
-
-	ch <- nil
-	duration := time.Since(start)
-	log.Printf("Loading manufacturers took %v", duration)
-}
-
-func (app *App) loadCarModels(wg *sync.WaitGroup, client *http.Client, ch chan error) {
-	defer wg.Done()
-	start := time.Now()
-	resp, err := client.Get("http://localhost:3000/api/models")
-	if err != nil {
-		ch <- err
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		ch <- err
-		return
-	}
-
-	if err := json.Unmarshal(body, &app.carModels); err != nil {
-		ch <- err
-		return
-	}
-
-	ch <- nil
-	duration := time.Since(start)
-	log.Printf("Loading car models took %v", duration)
-}
-
-func (app *App) loadCategories(wg *sync.WaitGroup, client *http.Client, ch chan error) {
-	defer wg.Done()
-	start := time.Now()
-	resp, err := client.Get("http://localhost:3000/api/categories")
-	if err != nil {
-		ch <- err
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		ch <- err
-		return
-	}
-
-	if err := json.Unmarshal(body, &app.categories); err != nil {
-		ch <- err
-		return
-	}
-
-	ch <- nil
-	duration := time.Since(start)
-	log.Printf("Loading categories took %v", duration)
-}
-
 func (app *App) getUniqueCountries(manufacturers []structs.Manufacturer) []string {
 	uniqueCountries := make(map[string]bool)
 	var countries []string
@@ -455,48 +489,147 @@ func (app *App) getUniqueYears(carModels []structs.CarModel) []int {
 }
 
 func (app *App) filterHandler(w http.ResponseWriter, r *http.Request) {
-	r.ParseForm()
-	manufacturerID := r.FormValue("manufacturer")
-	categoryID := r.FormValue("category")
-	year := r.FormValue("year")
-	country := r.FormValue("country")
+	if !app.allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	data, err := app.filterPageData(r)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+	app.respond(w, r, "layout.html", data)
+}
+
+// filterPageData builds the data behind /filter and its fragments, so the
+// full page and the pieces swapped into it are always rendered from the
+// same results. Results are paginated only when page or per_page is given;
+// NextPage then points at the grid fragment for the following page.
+func (app *App) filterPageData(r *http.Request) (structs.PageData, error) {
+	if err := r.ParseForm(); err != nil {
+		return structs.PageData{}, errBadRequest("The filter form could not be read.")
+	}
+	c := app.snapshot()
+	filter := carFilterFromRequest(r)
+	filteredCars := c.filterCars(filter)
+	noResults := len(filteredCars) == 0
+
+	nextPage := ""
+	if r.URL.Query().Has("page") || r.URL.Query().Has("per_page") {
+		page, err := parsePagination(r)
+		if err != nil {
+			return structs.PageData{}, err
+		}
+		start, end, meta := page.window(len(filteredCars))
+		filteredCars = filteredCars[start:end]
+		if page.Page < meta.TotalPages {
+			q := filter.values()
+			q.Set("page", strconv.Itoa(page.Page+1))
+			q.Set("per_page", strconv.Itoa(page.PerPage))
+			nextPage = "/fragments/grid?" + q.Encode()
+		}
+	}
 
+	return structs.PageData{
+		Title:                 "Aurora cars",
+		Manufacturers:         c.Manufacturers,
+		CarModels:             filteredCars,
+		Categories:            c.Categories,
+		Countries:             app.getUniqueCountries(c.Manufacturers),
+		Years:                 app.getUniqueYears(c.CarModels),
+		SelectedManufacturers: []string{filter.Manufacturer},
+		SelectedCategories:    []string{filter.Category},
+		SelectedYears:         []string{filter.Year},
+		SelectedCountries:     []string{filter.Country},
+		Query:                 filter.Query,
+		NoResults:             noResults,
+		NextPage:              nextPage,
+	}, nil
+}
+
+// fragmentHandler serves /fragments/grid and /fragments/facets: the results
+// grid and the filter panel of /filter for the same parameters, for pages
+// that update in place instead of reloading.
+func (app *App) fragmentHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	params, _ := matchRoute("/fragments/{name}", r.URL.Path)
+	name := params["name"]
+	if name != "grid" && name != "facets" {
+		app.notFoundHandler(w, r)
+		return
+	}
+	data, err := app.filterPageData(r)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+	app.render(w, r, name, data)
+}
+
+// carFilter holds the browse parameters shared by the HTML pages and the
+// JSON API. Empty fields match every car.
+type carFilter struct {
+	Manufacturer string
+	Category     string
+	Year         string
+	Country      string
+	Query        string
+}
+
+// values encodes the filter as the query parameters carFilterFromRequest
+// reads.
+func (f carFilter) values() url.Values {
+	q := url.Values{}
+	for name, value := range map[string]string{
+		"manufacturer": f.Manufacturer,
+		"category":     f.Category,
+		"year":         f.Year,
+		"country":      f.Country,
+		"query":        f.Query,
+	} {
+		if value != "" {
+			q.Set(name, value)
+		}
+	}
+	return q
+}
+
+func carFilterFromRequest(r *http.Request) carFilter {
+	return carFilter{
+		Manufacturer: r.FormValue("manufacturer"),
+		Category:     r.FormValue("category"),
+		Year:         r.FormValue("year"),
+		Country:      r.FormValue("country"),
+		Query:        strings.ToLower(r.FormValue("query")),
+	}
+}
+
+func (c catalog) filterCars(filter carFilter) []structs.CarModel {
 	filteredCars := []structs.CarModel{}
-	for _, car := range app.carModels {
-		if manufacturerID != "" && strconv.Itoa(car.ManufacturerID) != manufacturerID {
+	for _, car := range c.CarModels {
+		if filter.Manufacturer != "" && strconv.Itoa(car.ManufacturerID) != filter.Manufacturer {
 			continue
 		}
-		if categoryID != "" && strconv.Itoa(car.CategoryID) != categoryID {
+		if filter.Category != "" && strconv.Itoa(car.CategoryID) != filter.Category {
 			continue
 		}
-		if year != "" && strconv.Itoa(car.Year) != year {
+		if filter.Year != "" && strconv.Itoa(car.Year) != filter.Year {
 			continue
 		}
-		if country != "" && !app.isCarFromCountry(car, country) {
+		if filter.Country != "" && !c.isCarFromCountry(car, filter.Country) {
+			continue
+		}
+		if filter.Query != "" && !c.matchesQuery(car, filter.Query) {
 			continue
 		}
 		filteredCars = append(filteredCars, car)
 	}
-
-	data := structs.PageData{
-		Title:                 "Aurora cars",
-		Manufacturers:         app.manufacturers,
-		CarModels:             filteredCars,
-		Categories:            app.categories,
-		Countries:             app.getUniqueCountries(app.manufacturers),
-		Years:                 app.getUniqueYears(app.carModels),
-		SelectedManufacturers: []string{manufacturerID},
-		SelectedCategories:    []string{categoryID},
-		SelectedYears:         []string{year},
-		SelectedCountries:     []string{country},
-		NoResults:             len(filteredCars) == 0,
-	}
-
-	app.templates.ExecuteTemplate(w, "layout.html", data)
+	return filteredCars
 }
 
-func (app *App) isCarFromCountry(car structs.CarModel, country string) bool {
-	for _, manufacturer := range app.manufacturers {
+func (c catalog) isCarFromCountry(car structs.CarModel, country string) bool {
+	for _, manufacturer := range c.Manufacturers {
 		if manufacturer.ID == car.ManufacturerID && manufacturer.Country == country {
 			return true
 		}
@@ -517,42 +650,47 @@ func secureHeaders(next http.Handler) http.Handler {
 }
 
 func (app *App) searchHandler(w http.ResponseWriter, r *http.Request) {
-	query := strings.ToLower(r.URL.Query().Get("query"))
-	var results []structs.CarModel
-
-	for _, car := range app.carModels {
-		manName := app.getManufacturerNameByID(car.ManufacturerID)
-		catName := app.getCategoryNameByID(car.CategoryID)
-
-		country := app.getCountryByManufacturerID(car.ManufacturerID)
-
-		searchText := strings.ToLower(
-			car.Name + " " +
-				strconv.Itoa(car.Year) + " " +
-				manName + " " +
-				catName + " " +
-				country,
-		)
-
-		if strings.Contains(searchText, query) {
-			results = append(results, car)
-		}
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
 	}
 
+	c := app.snapshot()
+	query := strings.ToLower(r.URL.Query().Get("query"))
+	results := c.filterCars(carFilter{Query: query})
+
 	data := structs.PageData{
 		Title:         "Search Results",
 		CarModels:     results,
-		Manufacturers: app.manufacturers,
-		Categories:    app.categories,
-		Countries:     app.getUniqueCountries(app.manufacturers),
-		Years:         app.getUniqueYears(app.carModels),
+		Manufacturers: c.Manufacturers,
+		Categories:    c.Categories,
+		Countries:     app.getUniqueCountries(c.Manufacturers),
+		Years:         app.getUniqueYears(c.CarModels),
 		Query:         query,
 	}
-	app.templates.ExecuteTemplate(w, "layout.html", data)
+	app.respond(w, r, "layout.html", data)
 }
 
-func (app *App) getCountryByManufacturerID(id int) string {
-	for _, m := range app.manufacturers {
+// matchesQuery reports whether the lowercased query appears in the car's
+// name, year, manufacturer, category or country.
+func (c catalog) matchesQuery(car structs.CarModel, query string) bool {
+	manName := c.getManufacturerNameByID(car.ManufacturerID)
+	catName := c.categoryName(car.CategoryID)
+
+	country := c.getCountryByManufacturerID(car.ManufacturerID)
+
+	searchText := strings.ToLower(
+		car.Name + " " +
+			strconv.Itoa(car.Year) + " " +
+			manName + " " +
+			catName + " " +
+			country,
+	)
+
+	return strings.Contains(searchText, query)
+}
+
+func (c catalog) getCountryByManufacturerID(id int) string {
+	for _, m := range c.Manufacturers {
 		if m.ID == id {
 			return m.Country
 		}
@@ -560,8 +698,8 @@ func (app *App) getCountryByManufacturerID(id int) string {
 	return ""
 }
 
-func (app *App) getManufacturerNameByID(id int) string {
-	for _, m := range app.manufacturers {
+func (c catalog) getManufacturerNameByID(id int) string {
+	for _, m := range c.Manufacturers {
 		if m.ID == id {
 			return m.Name
 		}
@@ -569,43 +707,24 @@ func (app *App) getManufacturerNameByID(id int) string {
 	return ""
 }
 
-func (app *App) getCategoryNameByID(id int) string {
-	for _, cat := range app.categories {
-		if cat.ID == id {
-			return cat.Name
-		}
+func (app *App) compareHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
 	}
-	return ""
-}
-
-func (app *App) getManufacturerCountry(manufacturerID int) string {
-	for _, manufacturer := range app.manufacturers {
-		if manufacturer.ID == manufacturerID {
-			return manufacturer.Country
-		}
+	if err := r.ParseForm(); err != nil {
+		app.renderError(w, r, errBadRequest("The comparison form could not be read."))
+		return
 	}
-	return ""
-}
-
-func (app *App) compareHandler(w http.ResponseWriter, r *http.Request) {
-	r.ParseForm()
-	carIDs := r.Form["car_ids"] 
-
-	var carsToCompare []structs.CarModel
-	for _, idStr := range carIDs {
-		id, err := strconv.Atoi(idStr)
-		if err == nil {
-			for _, car := range app.carModels {
-				if car.ID == id {
-					carsToCompare = append(carsToCompare, car)
-				}
-			}
-		}
+	c := app.snapshot()
+	carsToCompare, err := c.carsToCompare(r.Form["car_ids"])
+	if err != nil {
+		app.renderError(w, r, err)
+		return
 	}
 
 	manuMap := make(map[int]structs.Manufacturer)
 	for _, car := range carsToCompare {
-		for _, manufacturer := range app.manufacturers {
+		for _, manufacturer := range c.Manufacturers {
 			if manufacturer.ID == car.ManufacturerID {
 				manuMap[car.ManufacturerID] = manufacturer
 				break
@@ -619,5 +738,21 @@ func (app *App) compareHandler(w http.ResponseWriter, r *http.Request) {
 		ManuMap:   manuMap,
 	}
 
-	app.templates.ExecuteTemplate(w, "compare.html", data)
-}
\ No newline at end of file
+	app.respond(w, r, "compare.html", data)
+}
+
+func (c catalog) carsToCompare(carIDs []string) ([]structs.CarModel, error) {
+	carsToCompare := []structs.CarModel{}
+	for _, idStr := range carIDs {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return nil, errBadRequest(fmt.Sprintf("Invalid car ID %q. Car IDs are whole numbers.", idStr))
+		}
+		for _, car := range c.CarModels {
+			if car.ID == id {
+				carsToCompare = append(carsToCompare, car)
+			}
+		}
+	}
+	return carsToCompare, nil
+}
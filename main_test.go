@@ -2,6 +2,8 @@ package main
 
 import (
 	"cars/structs"
+	"encoding/json"
+	"html/template"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -17,22 +19,104 @@ func setupTestRequest(method, url string) (*http.Request, *httptest.ResponseReco
 	return req, rr, nil
 }
 
-func TestIndexHandler(t *testing.T) { 
-	req, err := http.NewRequest("GET", "/", nil) 
+func testTemplates() *template.Template {
+	return template.Must(parseTemplates(bundledFS("templates", ""), nil))
+}
+
+func setupApp() *App {
+	return &App{
+		templates: testTemplates(),
+		config:    Config{AllowedHosts: []string{"example.com"}},
+		catalog: catalog{
+			CarModels: []structs.CarModel{
+				{ID: 1, Name: "Test Car", ManufacturerID: 1, CategoryID: 1, Year: 2020},
+			},
+			Manufacturers: []structs.Manufacturer{
+				{ID: 1, Name: "Test Manufacturer", Country: "Testland"},
+			},
+			Categories: []structs.Category{
+				{ID: 1, Name: "SUV"},
+			},
+		},
+	}
+}
+
+// newTestAPI serves app's catalog the way the Node API does, so handlers that
+// reload data can be exercised without it.
+func newTestAPI(app *App) *httptest.Server {
+	mux := http.NewServeMux()
+	serve := func(path string, v interface{}) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(v)
+		})
+	}
+	serve("/api/manufacturers", app.catalog.Manufacturers)
+	serve("/api/models", app.catalog.CarModels)
+	serve("/api/categories", app.catalog.Categories)
+	return httptest.NewServer(mux)
+}
+
+func TestIndexHandler(t *testing.T) {
+	app := setupApp()
+	api := newTestAPI(app)
+	defer api.Close()
+	app.config.APIURL = api.URL
+
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
 		t.Fatal(err)
 	}
 
-	rr := httptest.NewRecorder() 
-	handler := http.HandlerFunc(indexHandler)
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(app.indexHandler)
 
 	handler.ServeHTTP(rr, req)
 
-	if status := rr.Code; status != http.StatusOK { 
-		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK) 
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 
-	if !strings.Contains(rr.Body.String(), "Car Viewer") { 
-		t.Errorf("handler returned unexpected body: got %v", rr.Body.String()) 
+	if !strings.Contains(rr.Body.String(), "Aurora cars") {
+		t.Errorf("handler returned unexpected body: got %v", rr.Body.String())
+	}
+}
+
+func TestIndexHandler_APIUnavailable(t *testing.T) {
+	app := setupApp()
+	api := newTestAPI(app)
+	app.config.APIURL = api.URL
+	api.Close()
+
+	req, rr, err := setupTestRequest("GET", "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	http.HandlerFunc(app.indexHandler).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusServiceUnavailable)
+	}
+	if !strings.Contains(rr.Body.String(), "Could not connect to the API server") {
+		t.Errorf("handler returned unexpected body: got %v", rr.Body.String())
+	}
+}
+
+func TestIndexHandler_MethodNotAllowed(t *testing.T) {
+	app := setupApp()
+
+	req, rr, err := setupTestRequest("DELETE", "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	http.HandlerFunc(app.indexHandler).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusMethodNotAllowed {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusMethodNotAllowed)
+	}
+	if allow := rr.Header().Get("Allow"); allow != "GET" {
+		t.Errorf("expected Allow header GET, got %q", allow)
 	}
 }
 
@@ -42,8 +126,9 @@ func TestErrorHandler(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	app := setupApp()
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(errorHandler)
+	handler := http.HandlerFunc(app.errorHandler)
 
 	handler.ServeHTTP(rr, req)
 
@@ -62,8 +147,9 @@ func TestNotFoundHandler(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	app := setupApp()
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(notFoundHandler)
+	handler := http.HandlerFunc(app.notFoundHandler)
 
 	handler.ServeHTTP(rr, req)
 
@@ -82,8 +168,9 @@ func TestHealthCheckHandler(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	app := setupApp()
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(healthCheckHandler)
+	handler := http.HandlerFunc(app.healthCheckHandler)
 
 	handler.ServeHTTP(rr, req)
 
@@ -97,12 +184,16 @@ func TestHealthCheckHandler(t *testing.T) {
 }
 func TestCarDetailsHandler_ValidID(t *testing.T) {
 	app := &App{
-		carModels: []structs.CarModel{
-			{ID: 1, Name: "Test Car", ManufacturerID: 1},
-		},
-		manufacturers: []structs.Manufacturer{
-			{ID: 1, Name: "Test Manufacturer"},
+		catalog: catalog{
+			CarModels: []structs.CarModel{
+				{ID: 1, Name: "Test Car", ManufacturerID: 1},
+			},
+			Manufacturers: []structs.Manufacturer{
+				{ID: 1, Name: "Test Manufacturer"},
+			},
 		},
+		config:    Config{SiteURL: "http://cars.example"},
+		templates: testTemplates(),
 	}
 
 	req, rr, err := setupTestRequest("GET", "/car?id=1")
@@ -154,15 +245,18 @@ func TestCarDetailsHandler_InvalidID_Format(t *testing.T) {
 
 func TestFilterHandler_NoResults(t *testing.T) {
 	app := &App{
-		carModels: []structs.CarModel{
-			{ID: 1, Name: "Car A", ManufacturerID: 1, CategoryID: 1, Year: 2020},
-		},
-		manufacturers: []structs.Manufacturer{
-			{ID: 1, Name: "Manufacturer A"},
-		},
-		categories: []structs.Category{
-			{ID: 1, Name: "SUV"},
+		catalog: catalog{
+			CarModels: []structs.CarModel{
+				{ID: 1, Name: "Car A", ManufacturerID: 1, CategoryID: 1, Year: 2020},
+			},
+			Manufacturers: []structs.Manufacturer{
+				{ID: 1, Name: "Manufacturer A"},
+			},
+			Categories: []structs.Category{
+				{ID: 1, Name: "SUV"},
+			},
 		},
+		templates: testTemplates(),
 	}
 
 	req, rr, err := setupTestRequest("GET", "/filter?manufacturer=2")
@@ -184,9 +278,12 @@ func TestFilterHandler_NoResults(t *testing.T) {
 
 func TestSearchHandler_NoResults(t *testing.T) {
 	app := &App{
-		carModels: []structs.CarModel{
-			{ID: 1, Name: "Car A"},
+		catalog: catalog{
+			CarModels: []structs.CarModel{
+				{ID: 1, Name: "Car A"},
+			},
 		},
+		templates: testTemplates(),
 	}
 
 	req, rr, err := setupTestRequest("GET", "/search?query=NotExist")
@@ -207,6 +304,7 @@ func TestSearchHandler_NoResults(t *testing.T) {
 }
 
 func TestInvalidPath(t *testing.T) {
+	app := setupApp()
 	req, rr := httptest.NewRequest("GET", "/nonexistent", nil), httptest.NewRecorder()
 	handler := http.HandlerFunc(app.notFoundHandler)
 	handler.ServeHTTP(rr, req)
@@ -215,3 +313,42 @@ func TestInvalidPath(t *testing.T) {
 		t.Errorf("Expected status 404 for non-existent path, got %v", rr.Code)
 	}
 }
+
+func TestErrorResponses_JSON(t *testing.T) {
+	app := setupApp()
+
+	req := httptest.NewRequest("GET", "/car?id=abc", nil)
+	req.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(app.CarDetailsHandler).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP 400 status, got: %v", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Expected JSON content type, got: %v", ct)
+	}
+
+	var body struct {
+		Error struct {
+			Status  int    `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.Status != http.StatusBadRequest || !strings.Contains(body.Error.Message, "Invalid car ID") {
+		t.Errorf("Unexpected JSON error body: %+v", body)
+	}
+}
+
+func TestCompareHandler_InvalidID(t *testing.T) {
+	app := setupApp()
+	req, rr := httptest.NewRequest("GET", "/compare?car_ids=1&car_ids=x", nil), httptest.NewRecorder()
+	http.HandlerFunc(app.compareHandler).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP 400 status, got: %v", rr.Code)
+	}
+}
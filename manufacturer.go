@@ -0,0 +1,108 @@
+package main
+
+import (
+	"cars/structs"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+func manufacturerURL(name string) string {
+	return "/manufacturers/" + slugify(name)
+}
+
+// manufacturerHandler serves both /manufacturer?id=3 and the pretty
+// /manufacturers/bmw form. The pretty form also accepts a numeric ID.
+func (app *App) manufacturerHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	key := r.URL.Query().Get("id")
+	if params, ok := matchRoute("/manufacturers/{slug}", r.URL.Path); ok {
+		key = params["slug"]
+	}
+
+	c := app.snapshot()
+	manufacturer := c.findManufacturer(key)
+	if manufacturer == nil {
+		app.renderError(w, r, errNotFound("Manufacturer not found."))
+		return
+	}
+
+	var lineup []structs.CarModel
+	for _, car := range c.CarModels {
+		if car.ManufacturerID == manufacturer.ID {
+			lineup = append(lineup, car)
+		}
+	}
+
+	data := struct {
+		Title        string
+		Manufacturer *structs.Manufacturer
+		CarModels    []structs.CarModel
+		Stats        structs.LineupStats
+	}{
+		Title:        manufacturer.Name + " - Aurora Cars",
+		Manufacturer: manufacturer,
+		CarModels:    lineup,
+		Stats:        c.lineupStats(lineup),
+	}
+
+	app.render(w, r, "manufacturer.html", data)
+}
+
+func (c catalog) findManufacturer(key string) *structs.Manufacturer {
+	if key == "" {
+		return nil
+	}
+	id, err := strconv.Atoi(key)
+	for i, m := range c.Manufacturers {
+		if (err == nil && m.ID == id) || slugify(m.Name) == key {
+			return &c.Manufacturers[i]
+		}
+	}
+	return nil
+}
+
+func (c catalog) lineupStats(cars []structs.CarModel) structs.LineupStats {
+	stats := structs.LineupStats{ModelCount: len(cars)}
+	if len(cars) == 0 {
+		return stats
+	}
+
+	stats.MinHorsepower, stats.MaxHorsepower = cars[0].Specifications.Horsepower, cars[0].Specifications.Horsepower
+	stats.FirstYear, stats.LastYear = cars[0].Year, cars[0].Year
+	totalHorsepower := 0
+	for _, car := range cars {
+		stats.MinHorsepower = min(stats.MinHorsepower, car.Specifications.Horsepower)
+		stats.MaxHorsepower = max(stats.MaxHorsepower, car.Specifications.Horsepower)
+		stats.FirstYear = min(stats.FirstYear, car.Year)
+		stats.LastYear = max(stats.LastYear, car.Year)
+		totalHorsepower += car.Specifications.Horsepower
+
+		stats.Categories = appendUnique(stats.Categories, c.categoryName(car.CategoryID))
+		stats.Drivetrains = appendUnique(stats.Drivetrains, car.Specifications.Drivetrain)
+		stats.Transmissions = appendUnique(stats.Transmissions, car.Specifications.Transmission)
+	}
+	stats.AverageHorsepower = totalHorsepower / len(cars)
+	sort.Strings(stats.Categories)
+	sort.Strings(stats.Drivetrains)
+	sort.Strings(stats.Transmissions)
+	return stats
+}
+
+func appendUnique(slice []string, value string) []string {
+	if value == "" || contains(slice, value) {
+		return slice
+	}
+	return append(slice, value)
+}
+
+// setManufacturerNames fills CarModel.ManufacturerName, which the API leaves
+// empty, so templates can show and link a car's brand without a lookup.
+func (c catalog) setManufacturerNames() {
+	for i := range c.CarModels {
+		c.CarModels[i].ManufacturerName = c.getManufacturerNameByID(c.CarModels[i].ManufacturerID)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"cars/structs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func setupCatalogApp(t *testing.T) *App {
+	t.Helper()
+	tmpl, err := parseTemplates(bundledFS("templates", ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &App{
+		templates: tmpl,
+		config:    Config{AllowedHosts: []string{"example.com", "cars.example"}},
+		catalog: catalog{
+			Manufacturers: []structs.Manufacturer{
+				{ID: 1, Name: "Mercedes-Benz", Country: "Germany", Founded: 1926},
+				{ID: 2, Name: "Toyota", Country: "Japan", Founded: 1937},
+			},
+			Categories: []structs.Category{
+				{ID: 1, Name: "SUV"},
+				{ID: 2, Name: "Sedan"},
+			},
+			CarModels: []structs.CarModel{
+				{ID: 1, Name: "Mercedes-Benz GLE", ManufacturerID: 1, CategoryID: 1, Year: 2022,
+					Specifications: structs.Specifications{Horsepower: 362}},
+				{ID: 2, Name: "Mercedes-Benz E-Class", ManufacturerID: 1, CategoryID: 2, Year: 2023,
+					Specifications: structs.Specifications{Horsepower: 255}},
+				{ID: 3, Name: "Toyota Corolla", ManufacturerID: 2, CategoryID: 2, Year: 2023,
+					Specifications: structs.Specifications{Horsepower: 139}},
+			},
+		},
+	}
+	app.catalog.setManufacturerNames()
+	return app
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Mercedes-Benz E-Class": "mercedes-benz-e-class",
+		"BMW 3 Series":          "bmw-3-series",
+		"  Ford F-150 (2023) ":  "ford-f-150-2023",
+		"United States":         "united-states",
+	}
+	for in, want := range tests {
+		if got := slugify(in); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestManufacturerHandler(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	for _, url := range []string{"/manufacturer?id=1", "/manufacturers/mercedes-benz", "/manufacturers/1"} {
+		req, rr := httptest.NewRequest("GET", url, nil), httptest.NewRecorder()
+		app.manufacturerHandler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %v", url, rr.Code)
+		}
+		body := rr.Body.String()
+		for _, want := range []string{"Mercedes-Benz GLE", "Mercedes-Benz E-Class", "255&ndash;362 hp", `href="/categories/suv"`, `href="/countries/germany"`, "2022&ndash;2023"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: expected body to contain %q", url, want)
+			}
+		}
+		if strings.Contains(body, "Toyota Corolla") {
+			t.Errorf("%s: lineup contains another manufacturer's model", url)
+		}
+	}
+}
+
+func TestManufacturerHandler_NotFound(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	for _, url := range []string{"/manufacturer?id=99", "/manufacturer", "/manufacturers/lada"} {
+		req, rr := httptest.NewRequest("GET", url, nil), httptest.NewRecorder()
+		app.manufacturerHandler(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %v", url, rr.Code)
+		}
+	}
+}
+
+func TestLineupStats_Empty(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	stats := app.snapshot().lineupStats(nil)
+	if stats.ModelCount != 0 || stats.Categories != nil {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+}
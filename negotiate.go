@@ -0,0 +1,84 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// wantsJSON reports whether the client asked for JSON rather than HTML. An
+// explicit ?format=json or ?format=html wins; /api/, /graphql and /oembed are
+// always JSON; otherwise the Accept header decides, with HTML preferred on a tie so
+// browsers sending */* keep getting pages.
+func wantsJSON(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "json":
+		return true
+	case "html":
+		return false
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/graphql" || r.URL.Path == "/oembed" {
+		return true
+	}
+	return acceptQuality(r, "application/json") > acceptQuality(r, "text/html")
+}
+
+// acceptQuality returns the q-value the Accept header gives mediaType, using
+// the most specific matching range. Only exact matches count for JSON so a
+// bare */* never turns a page into data.
+func acceptQuality(r *http.Request, mediaType string) float64 {
+	best, bestSpecificity := 0.0, -1
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		accepted, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		specificity := -1
+		switch {
+		case accepted == mediaType:
+			specificity = 2
+		case mediaType == "text/html" && accepted == "text/*":
+			specificity = 1
+		case mediaType == "text/html" && accepted == "*/*":
+			specificity = 0
+		}
+		if specificity < bestSpecificity || specificity < 0 {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		best, bestSpecificity = q, specificity
+	}
+	if r.Header.Get("Accept") == "" && mediaType == "text/html" {
+		return 1
+	}
+	return best
+}
+
+// respond sends data as JSON when the client negotiated it and otherwise
+// renders the named template with it. Browse pages go through here so the
+// HTML and JSON views can never disagree about the underlying data.
+func (app *App) respond(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	varyAccept(w)
+	if wantsJSON(r) {
+		app.writeJSON(w, http.StatusOK, data)
+		return
+	}
+	app.render(w, r, name, data)
+}
+
+func varyAccept(w http.ResponseWriter) {
+	for _, v := range w.Header().Values("Vary") {
+		if strings.EqualFold(v, "Accept") {
+			return
+		}
+	}
+	w.Header().Add("Vary", "Accept")
+}
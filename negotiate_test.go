@@ -0,0 +1,103 @@
+package main
+
+import (
+	"cars/structs"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		url, accept string
+		want        bool
+	}{
+		{"/search", "", false},
+		{"/search", "*/*", false},
+		{"/search", "text/html,application/xhtml+xml,*/*;q=0.8", false},
+		{"/search", "application/json", true},
+		{"/search", "application/json, text/html", false},
+		{"/search", "text/html;q=0.5, application/json", true},
+		{"/search?format=json", "text/html", true},
+		{"/search?format=html", "application/json", false},
+		{"/api/v1/cars", "", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		if got := wantsJSON(req); got != tt.want {
+			t.Errorf("wantsJSON(%q, Accept %q) = %v, want %v", tt.url, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestBrowseHandlers_JSON(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	tests := []struct {
+		url     string
+		accept  string
+		handler http.HandlerFunc
+		check   func(body []byte) bool
+	}{
+		{"/filter?country=Japan&format=json", "", app.filterHandler, func(body []byte) bool {
+			var data structs.PageData
+			return json.Unmarshal(body, &data) == nil && len(data.CarModels) == 1 && data.CarModels[0].ID == 3
+		}},
+		{"/search?query=mercedes", "application/json", app.searchHandler, func(body []byte) bool {
+			var data structs.PageData
+			return json.Unmarshal(body, &data) == nil && len(data.CarModels) == 2 && data.Query == "mercedes"
+		}},
+		{"/cars/3/toyota-corolla-2023?format=json", "", app.CarDetailsHandler, func(body []byte) bool {
+			var data struct {
+				Car          structs.CarModel     `json:"car"`
+				Manufacturer structs.Manufacturer `json:"manufacturer"`
+			}
+			return json.Unmarshal(body, &data) == nil && data.Car.ID == 3 && data.Manufacturer.Name == "Toyota"
+		}},
+		{"/compare?car_ids=1&car_ids=2", "application/json", app.compareHandler, func(body []byte) bool {
+			var data structs.PageData
+			return json.Unmarshal(body, &data) == nil && len(data.CarModels) == 2 && data.ManuMap[1].Name == "Mercedes-Benz"
+		}},
+	}
+	for _, tt := range tests {
+		req, rr := httptest.NewRequest("GET", tt.url, nil), httptest.NewRecorder()
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		tt.handler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", tt.url, rr.Code)
+			continue
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s: expected JSON, got Content-Type %q", tt.url, ct)
+		}
+		if vary := rr.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("%s: expected Vary: Accept, got %q", tt.url, vary)
+		}
+		if !tt.check(rr.Body.Bytes()) {
+			t.Errorf("%s: unexpected body: %s", tt.url, rr.Body.String())
+		}
+	}
+}
+
+func TestBrowseHandlers_HTMLVariesOnAccept(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	req, rr := httptest.NewRequest("GET", "/search?query=toyota", nil), httptest.NewRecorder()
+	req.Header.Set("Accept", "text/html")
+	app.searchHandler(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected HTML, got Content-Type %q", ct)
+	}
+	if vary := rr.Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("expected Vary: Accept, got %q", vary)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"cars/structs"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// imageDir holds uploaded car photos, served under /img/ along with the
+// stock photos built into the binary. It is set by the -images flag, and
+// tests point it at a temporary directory.
+var imageDir = "api/img"
+
+// Share images use the size OpenGraph and Twitter recommend for large
+// previews.
+const (
+	ogImageWidth  = 1200
+	ogImageHeight = 630
+)
+
+var (
+	ogBackgroundTop    = color.RGBA{0x25, 0x2b, 0x31, 0xff}
+	ogBackgroundBottom = color.RGBA{0x2e, 0x49, 0x51, 0xff}
+	ogAccent           = color.RGBA{0x00, 0xff, 0xb7, 0xff}
+	ogText             = color.RGBA{0xf7, 0xf9, 0xfc, 0xff}
+	ogSubtle           = color.RGBA{0xcf, 0xfb, 0xad, 0xff}
+)
+
+// ogImageRevision changes whenever renderOGImage draws differently, so
+// images from an older build are not taken for current ones.
+const ogImageRevision = "1"
+
+// ogImageKey identifies what a car's share image shows. It is the image's
+// ETag, so it must only depend on what is drawn, which stays the same
+// across restarts.
+func ogImageKey(car structs.CarModel, manufacturer structs.Manufacturer, category string) string {
+	specs := car.Specifications
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%q\x00%d\x00%q\x00%q\x00%d\x00%q\x00%q\x00%q\x00%q",
+		ogImageRevision, car.Name, car.Year, car.Image, manufacturer.Name,
+		specs.Horsepower, specs.Engine, specs.Transmission, specs.Drivetrain, category)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ogImageCache keeps the latest rendered PNG of each car along with its
+// key; a car whose key has changed is drawn again.
+type ogImageCache struct {
+	mu     sync.Mutex
+	images map[int]ogImage
+}
+
+type ogImage struct {
+	key string
+	png []byte
+}
+
+func (c *ogImageCache) get(id int, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	img, ok := c.images[id]
+	return img.png, ok && img.key == key
+}
+
+func (c *ogImageCache) put(id int, key string, png []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.images == nil {
+		c.images = make(map[int]ogImage)
+	}
+	c.images[id] = ogImage{key: key, png: png}
+}
+
+// ogImageHandler serves /og/car/{id}.png, the preview image car pages
+// advertise in their OpenGraph tags.
+func (app *App) ogImageHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	params, _ := matchRoute("/og/car/{file}", r.URL.Path)
+	idStr, isPNG := strings.CutSuffix(params["file"], ".png")
+	id, err := strconv.Atoi(idStr)
+	if !isPNG || err != nil {
+		app.notFoundHandler(w, r)
+		return
+	}
+
+	c := app.snapshot()
+	car, m := c.findCar(id)
+	if car == nil {
+		app.renderError(w, r, errNotFound("Car not found."))
+		return
+	}
+	var manufacturer structs.Manufacturer
+	if m != nil {
+		manufacturer = *m
+	}
+
+	category := c.categoryName(car.CategoryID)
+	key := ogImageKey(*car, manufacturer, category)
+	etag := `"og-` + key + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	img, ok := app.ogImages.get(id, key)
+	if !ok {
+		img, err = renderOGImage(*car, manufacturer, category)
+		if err != nil {
+			app.renderError(w, r, errInternal(err))
+			return
+		}
+		app.ogImages.put(id, key, img)
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(img)))
+	if r.Method != http.MethodHead {
+		w.Write(img)
+	}
+}
+
+func (c catalog) findCar(id int) (*structs.CarModel, *structs.Manufacturer) {
+	for i := range c.CarModels {
+		if c.CarModels[i].ID != id {
+			continue
+		}
+		for j := range c.Manufacturers {
+			if c.Manufacturers[j].ID == c.CarModels[i].ManufacturerID {
+				return &c.CarModels[i], &c.Manufacturers[j]
+			}
+		}
+		return &c.CarModels[i], nil
+	}
+	return nil, nil
+}
+
+func (c catalog) categoryName(id int) string {
+	for _, category := range c.Categories {
+		if category.ID == id {
+			return category.Name
+		}
+	}
+	return ""
+}
+
+// renderOGImage draws the car's photo on the right and its name, year,
+// manufacturer and key specs on a branded panel on the left. A missing or
+// unreadable photo leaves the panel on its own rather than failing.
+func renderOGImage(car structs.CarModel, manufacturer structs.Manufacturer, category string) ([]byte, error) {
+	canvas := image.NewRGBA(image.Rect(0, 0, ogImageWidth, ogImageHeight))
+	for y := 0; y < ogImageHeight; y++ {
+		line := image.Rect(0, y, ogImageWidth, y+1)
+		draw.Draw(canvas, line, image.NewUniform(blend(ogBackgroundTop, ogBackgroundBottom, y, ogImageHeight-1)), image.Point{}, draw.Src)
+	}
+
+	const panelWidth = 600
+	if photo, err := loadPhoto(car.Image); err == nil {
+		resizeCover(canvas, image.Rect(panelWidth, 0, ogImageWidth, ogImageHeight), photo)
+	}
+	draw.Draw(canvas, image.Rect(0, 0, 12, ogImageHeight), image.NewUniform(ogAccent), image.Point{}, draw.Src)
+
+	const left, maxWidth = 60, panelWidth - 100
+	y := 56
+	drawText(canvas, left, y, 3, ogAccent, "Aurora cars")
+	y += 21 + 40
+
+	for _, line := range wrapText(car.Name, 5, maxWidth, 3) {
+		drawText(canvas, left, y, 5, ogText, line)
+		y += 35 + 14
+	}
+	y += 12
+
+	subtitle := strconv.Itoa(car.Year)
+	if manufacturer.Name != "" {
+		subtitle += " " + manufacturer.Name
+	}
+	for _, line := range wrapText(subtitle, 4, maxWidth, 1) {
+		drawText(canvas, left, y, 4, ogSubtle, line)
+		y += 28 + 30
+	}
+
+	specs := car.Specifications
+	for _, spec := range []string{fmt.Sprintf("%d hp", specs.Horsepower), specs.Engine, specs.Transmission, specs.Drivetrain, category} {
+		if spec == "" || y > ogImageHeight-60 {
+			continue
+		}
+		for _, line := range wrapText(spec, 3, maxWidth, 1) {
+			drawText(canvas, left, y, 3, ogText, line)
+			y += 21 + 14
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func loadPhoto(name string) (image.Image, error) {
+	f, err := openImage(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// blend interpolates between a and b, step i of n.
+func blend(a, b color.RGBA, i, n int) color.RGBA {
+	mix := func(x, y uint8) uint8 { return uint8((int(x)*(n-i) + int(y)*i) / n) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xff}
+}
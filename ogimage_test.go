@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"cars/structs"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getOGImage(app *App, path string, header http.Header) *httptest.ResponseRecorder {
+	req, rr := httptest.NewRequest("GET", path, nil), httptest.NewRecorder()
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	app.ogImageHandler(rr, req)
+	return rr
+}
+
+func TestOGImage_RendersPNG(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.catalog.CarModels[2].Image = "toyota_corolla.jpg"
+
+	rr := getOGImage(app, "/og/car/3.png", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("unexpected response %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	img, err := png.Decode(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, ogImageWidth, ogImageHeight) {
+		t.Errorf("unexpected size %v", img.Bounds())
+	}
+	// The accent stripe is drawn down the left edge.
+	if got := color.RGBAModel.Convert(img.At(4, 300)); got != ogAccent {
+		t.Errorf("expected the accent stripe at the left edge, got %v", got)
+	}
+}
+
+func TestOGImage_CachedByContent(t *testing.T) {
+	app := setupCatalogApp(t)
+	api := newTestAPI(app)
+	defer api.Close()
+	app.config.APIURL = api.URL
+	if err := app.loadData(); err != nil {
+		t.Fatal(err)
+	}
+
+	first := getOGImage(app, "/og/car/1.png", nil)
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+	if rr := getOGImage(app, "/og/car/1.png", http.Header{"If-None-Match": {etag}}); rr.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", rr.Code)
+	}
+	key := etag[len(`"og-`) : len(etag)-1]
+	if cached, ok := app.ogImages.get(1, key); !ok || !bytes.Equal(cached, first.Body.Bytes()) {
+		t.Errorf("the rendered image should be cached under its ETag")
+	}
+
+	// Every visit to / reloads the data; an unchanged catalog keeps the ETag.
+	if err := app.loadData(); err != nil {
+		t.Fatal(err)
+	}
+	if rr := getOGImage(app, "/og/car/1.png", http.Header{"If-None-Match": {etag}}); rr.Code != http.StatusNotModified {
+		t.Errorf("reloading unchanged data should keep the image, got %d", rr.Code)
+	}
+
+	// A restart counts versions from scratch, but the image is the same.
+	restarted := setupCatalogApp(t)
+	restarted.catalog.Version = app.catalog.Version + 7
+	if rr := getOGImage(restarted, "/og/car/1.png", http.Header{"If-None-Match": {etag}}); rr.Code != http.StatusNotModified {
+		t.Errorf("the ETag should not depend on the catalog version, got %d %s", rr.Code, rr.Header().Get("ETag"))
+	}
+
+	// Changes to cars the image does not show keep it too.
+	next := app.snapshot()
+	next.CarModels = append([]structs.CarModel{}, next.CarModels...)
+	next.CarModels[1].Name = "Mercedes-Benz E-Class Estate"
+	app.setCatalog(next)
+	if rr := getOGImage(app, "/og/car/1.png", http.Header{"If-None-Match": {etag}}); rr.Code != http.StatusNotModified {
+		t.Errorf("editing another car should keep the image, got %d", rr.Code)
+	}
+
+	next = app.snapshot()
+	next.CarModels = append([]structs.CarModel{}, next.CarModels...)
+	next.CarModels[0].Name = "Mercedes-Benz GLE Coupe"
+	app.setCatalog(next)
+
+	second := getOGImage(app, "/og/car/1.png", http.Header{"If-None-Match": {etag}})
+	if second.Code != http.StatusOK || second.Header().Get("ETag") == etag {
+		t.Errorf("renaming the car should invalidate the image, got %d %s", second.Code, second.Header().Get("ETag"))
+	}
+	if bytes.Equal(first.Body.Bytes(), second.Body.Bytes()) {
+		t.Errorf("the image should be re-rendered with the new name")
+	}
+}
+
+func TestOGImage_NotFound(t *testing.T) {
+	app := setupCatalogApp(t)
+	for _, path := range []string{"/og/car/99.png", "/og/car/1.jpg", "/og/car/abc.png"} {
+		if rr := getOGImage(app, path, nil); rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	lines := wrapText("Mercedes-Benz C-Class Cabriolet", 5, 500, 3)
+	want := []string{"Mercedes-Benz", "C-Class", "Cabriolet"}
+	if len(lines) != len(want) {
+		t.Fatalf("got %q, want %q", lines, want)
+	}
+	for i := range want {
+		if lines[i] != want[i] || textWidth(lines[i], 5) > 500 {
+			t.Errorf("line %d: got %q, want %q within 500px", i, lines[i], want[i])
+		}
+	}
+
+	truncated := wrapText("one two three four five six seven", 5, 200, 2)
+	if len(truncated) != 2 || truncated[1][len(truncated[1])-3:] != "..." {
+		t.Errorf("overflowing text should end with an ellipsis, got %q", truncated)
+	}
+}
+
+func TestResizeCover_CropsToFill(t *testing.T) {
+	// A wide source: red left third, green middle, blue right third.
+	src := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for x := 0; x < 300; x++ {
+		c := color.RGBA{0xff, 0, 0, 0xff}
+		if x >= 100 && x < 200 {
+			c = color.RGBA{0, 0xff, 0, 0xff}
+		} else if x >= 200 {
+			c = color.RGBA{0, 0, 0xff, 0xff}
+		}
+		for y := 0; y < 100; y++ {
+			src.Set(x, y, c)
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, 50, 50))
+	resizeCover(dst, dst.Bounds(), src)
+	if got := dst.RGBAAt(25, 25); got != (color.RGBA{0, 0xff, 0, 0xff}) {
+		t.Errorf("a square crop of a wide image should keep the centre, got %v", got)
+	}
+}
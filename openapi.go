@@ -0,0 +1,201 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+type openAPIDoc struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	Summary    string                      `json:"summary"`
+	Parameters []openAPIParameter          `json:"parameters,omitempty"`
+	Responses  map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	AllOf                []*openAPISchema          `json:"allOf,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+func (app *App) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	app.writeJSON(w, http.StatusOK, buildOpenAPI(app.apiEndpoints()))
+}
+
+// buildOpenAPI describes endpoints, deriving every schema from the Go types
+// the handlers encode.
+func buildOpenAPI(endpoints []apiEndpoint) *openAPIDoc {
+	schemas := make(map[string]*openAPISchema)
+	doc := &openAPIDoc{
+		OpenAPI:    "3.0.3",
+		Info:       openAPIInfo{Title: "Aurora Cars API", Version: "1.0.0"},
+		Paths:      make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{Schemas: schemas},
+	}
+
+	errorResponse := &openAPIResponse{
+		Description: "Error",
+		Content:     jsonContent(schemaFor(reflect.TypeOf(apiErrorBody{}), schemas)),
+	}
+
+	for _, endpoint := range endpoints {
+		envelope := &openAPISchema{
+			Type:       "object",
+			Properties: map[string]*openAPISchema{"data": schemaFor(endpoint.Response, schemas)},
+			Required:   []string{"data"},
+		}
+		if endpoint.Paginated {
+			envelope.Properties["meta"] = schemaFor(reflect.TypeOf(apiMeta{}), schemas)
+			envelope.Properties["links"] = schemaFor(reflect.TypeOf(apiLinks{}), schemas)
+			envelope.Required = append(envelope.Required, "meta", "links")
+		}
+
+		operation := &openAPIOperation{
+			Summary: endpoint.Summary,
+			Responses: map[string]*openAPIResponse{
+				"200":     {Description: "OK", Content: jsonContent(envelope)},
+				"default": errorResponse,
+			},
+		}
+		for _, param := range endpoint.Params {
+			schema := &openAPISchema{Type: param.Type}
+			if param.Repeated {
+				schema = &openAPISchema{Type: "array", Items: schema}
+			}
+			operation.Parameters = append(operation.Parameters, openAPIParameter{
+				Name:        param.Name,
+				In:          param.In,
+				Description: param.Description,
+				Required:    param.In == "path",
+				Schema:      schema,
+			})
+		}
+		doc.Paths[endpoint.Path] = map[string]*openAPIOperation{"get": operation}
+	}
+	return doc
+}
+
+func jsonContent(schema *openAPISchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: schema}}
+}
+
+// schemaFor converts t to a schema. Named structs are added to schemas once
+// and referenced from then on.
+func schemaFor(t reflect.Type, schemas map[string]*openAPISchema) *openAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := schemas[name]; !ok {
+			schema := &openAPISchema{}
+			schemas[name] = schema
+			*schema = *structSchema(t, schemas)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + name}
+	}
+	return &openAPISchema{}
+}
+
+// structSchema describes a struct's JSON fields. Embedded structs, which
+// encoding/json flattens into the parent, become allOf references so that
+// apiCar is expressed as a CarModel plus its optional embeds.
+func structSchema(t reflect.Type, schemas map[string]*openAPISchema) *openAPISchema {
+	own := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	var embedded []*openAPISchema
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded = append(embedded, schemaFor(field.Type, schemas))
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		own.Properties[name] = schemaFor(field.Type, schemas)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			own.Required = append(own.Required, name)
+		}
+	}
+
+	if len(embedded) == 0 {
+		return own
+	}
+	return &openAPISchema{AllOf: append(embedded, own)}
+}
+
+// schemaName turns Go type names into component names, dropping the "api"
+// prefix used for the unexported response types: apiCar becomes "Car".
+func schemaName(t reflect.Type) string {
+	name := strings.TrimPrefix(t.Name(), "api")
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// sampleQueries exercises every field a response can contain, including the
+// optional embeds, so the schema check below sees the full payload.
+var sampleQueries = map[string]string{
+	"/api/v1/cars":          "/api/v1/cars?embed=manufacturer,category&per_page=2&page=1",
+	"/api/v1/cars/{id}":     "/api/v1/cars/1?embed=manufacturer,category",
+	"/api/v1/manufacturers": "/api/v1/manufacturers?per_page=1&page=2",
+	"/api/v1/categories":    "/api/v1/categories",
+	"/api/v1/compare":       "/api/v1/compare?ids=1,3&embed=manufacturer,category",
+}
+
+func TestOpenAPI_PathsMatchRegisteredRoutes(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.registerAPI(http.NewServeMux())
+	doc := buildOpenAPI(app.apiEndpoints())
+
+	var registered, documented []string
+	for _, route := range app.routes {
+		if strings.HasPrefix(route, "/api/") {
+			registered = append(registered, route)
+		}
+	}
+	for path := range doc.Paths {
+		documented = append(documented, path)
+	}
+	sort.Strings(registered)
+	sort.Strings(documented)
+
+	if strings.Join(registered, " ") != strings.Join(documented, " ") {
+		t.Errorf("spec paths %v do not match registered API routes %v", documented, registered)
+	}
+	for _, path := range documented {
+		if _, ok := sampleQueries[path]; !ok {
+			t.Errorf("no sample query for %s; add one so its responses are checked against the spec", path)
+		}
+	}
+}
+
+func TestOpenAPI_ResponsesMatchSchemas(t *testing.T) {
+	app := setupCatalogApp(t)
+	mux := http.NewServeMux()
+	app.registerAPI(mux)
+	doc := buildOpenAPI(app.apiEndpoints())
+
+	for path, item := range doc.Paths {
+		url, ok := sampleQueries[path]
+		if !ok {
+			continue
+		}
+		req, rr := httptest.NewRequest("GET", url, nil), httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", url, rr.Code)
+			continue
+		}
+
+		var body interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: invalid JSON: %v", url, err)
+			continue
+		}
+		schema := item["get"].Responses["200"].Content["application/json"].Schema
+		for _, problem := range validateSchema(doc, schema, body, "response") {
+			t.Errorf("%s: %s", url, problem)
+		}
+	}
+
+	req, rr := httptest.NewRequest("GET", "/api/v1/cars/99", nil), httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	var body interface{}
+	json.Unmarshal(rr.Body.Bytes(), &body)
+	schema := doc.Paths["/api/v1/cars/{id}"]["get"].Responses["default"].Content["application/json"].Schema
+	for _, problem := range validateSchema(doc, schema, body, "error") {
+		t.Errorf("error response: %s", problem)
+	}
+}
+
+func TestOpenAPI_SchemasFromGoTypes(t *testing.T) {
+	doc := buildOpenAPI(setupCatalogApp(t).apiEndpoints())
+
+	for _, name := range []string{"Manufacturer", "CarModel", "Specifications", "Category"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("missing component schema %s", name)
+		}
+	}
+	carModel := doc.Components.Schemas["CarModel"]
+	if got := carModel.Properties["specifications"]; got == nil || got.Ref != "#/components/schemas/Specifications" {
+		t.Errorf("CarModel.specifications should reference Specifications, got %+v", got)
+	}
+	if contains(carModel.Required, "manufacturerName") {
+		t.Errorf("omitempty field manufacturerName must not be required")
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	app := setupCatalogApp(t)
+	req, rr := httptest.NewRequest("GET", "/openapi.json", nil), httptest.NewRecorder()
+	app.openAPIHandler(rr, req)
+
+	var doc openAPIDoc
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" || len(doc.Paths) == 0 {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+}
+
+func validateSchema(doc *openAPIDoc, schema *openAPISchema, value interface{}, at string) []string {
+	schema = resolveSchema(doc, schema)
+
+	if len(schema.AllOf) > 0 {
+		merged := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+		for _, part := range schema.AllOf {
+			part = resolveSchema(doc, part)
+			for name, prop := range part.Properties {
+				merged.Properties[name] = prop
+			}
+			merged.Required = append(merged.Required, part.Required...)
+		}
+		schema = merged
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{at + ": expected object"}
+		}
+		var problems []string
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, at+": missing required property "+name)
+			}
+		}
+		for name, v := range obj {
+			prop, ok := schema.Properties[name]
+			if !ok && schema.AdditionalProperties == nil {
+				problems = append(problems, at+": undocumented property "+name)
+				continue
+			}
+			if !ok {
+				prop = schema.AdditionalProperties
+			}
+			problems = append(problems, validateSchema(doc, prop, v, at+"."+name)...)
+		}
+		return problems
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{at + ": expected array"}
+		}
+		var problems []string
+		for _, item := range items {
+			problems = append(problems, validateSchema(doc, schema.Items, item, at+"[]")...)
+		}
+		return problems
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return []string{at + ": expected integer"}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{at + ": expected number"}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return []string{at + ": expected string"}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{at + ": expected boolean"}
+		}
+	}
+	return nil
+}
+
+func resolveSchema(doc *openAPIDoc, schema *openAPISchema) *openAPISchema {
+	for schema.Ref != "" {
+		schema = doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Records are published unless their status says otherwise. Only published
+// records, and scheduled ones whose time has come, are on the public site;
+// the admin console sees them all.
+const (
+	statusDraft     = "draft"
+	statusScheduled = "scheduled"
+	statusPublished = "published"
+	statusArchived  = "archived"
+)
+
+var statuses = []string{statusDraft, statusScheduled, statusPublished, statusArchived}
+
+// isLive reports whether a record with this status and publish time is
+// public at now.
+func isLive(status, publishAt string, now time.Time) bool {
+	switch status {
+	case "", statusPublished:
+		return true
+	case statusScheduled:
+		at, err := time.Parse(time.RFC3339, publishAt)
+		return err == nil && !at.After(now)
+	}
+	return false
+}
+
+// public returns the part of the catalog visitors may see at now. Cars are
+// only shown while their manufacturer and category are too.
+func (c catalog) public(now time.Time) catalog {
+	out := c
+	out.Manufacturers = nil
+	manufacturers := make(map[int]bool)
+	for _, m := range c.Manufacturers {
+		if isLive(m.Status, m.PublishAt, now) {
+			out.Manufacturers = append(out.Manufacturers, m)
+			manufacturers[m.ID] = true
+		}
+	}
+	out.Categories = nil
+	categories := make(map[int]bool)
+	for _, category := range c.Categories {
+		if isLive(category.Status, category.PublishAt, now) {
+			out.Categories = append(out.Categories, category)
+			categories[category.ID] = true
+		}
+	}
+	out.CarModels = nil
+	for _, car := range c.CarModels {
+		if isLive(car.Status, car.PublishAt, now) && manufacturers[car.ManufacturerID] && categories[car.CategoryID] {
+			out.CarModels = append(out.CarModels, car)
+		}
+	}
+	return out
+}
+
+// nextPublishAt returns the earliest publish time of a scheduled record
+// after since.
+func (c catalog) nextPublishAt(since time.Time) (time.Time, bool) {
+	var next time.Time
+	consider := func(status, publishAt string) {
+		if status != statusScheduled {
+			return
+		}
+		at, err := time.Parse(time.RFC3339, publishAt)
+		if err == nil && at.After(since) && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	for _, m := range c.Manufacturers {
+		consider(m.Status, m.PublishAt)
+	}
+	for _, category := range c.Categories {
+		consider(category.Status, category.PublishAt)
+	}
+	for _, car := range c.CarModels {
+		consider(car.Status, car.PublishAt)
+	}
+	return next, !next.IsZero()
+}
+
+// publishDue marks the scheduled records whose time has come as published
+// and reports whether there were any.
+func publishDue(c *catalog, now time.Time) bool {
+	due := false
+	flip := func(status, publishAt *string) {
+		if *status == statusScheduled && isLive(*status, *publishAt, now) {
+			*status, *publishAt = "", ""
+			due = true
+		}
+	}
+	for i := range c.Manufacturers {
+		flip(&c.Manufacturers[i].Status, &c.Manufacturers[i].PublishAt)
+	}
+	for i := range c.Categories {
+		flip(&c.Categories[i].Status, &c.Categories[i].PublishAt)
+	}
+	for i := range c.CarModels {
+		flip(&c.CarModels[i].Status, &c.CarModels[i].PublishAt)
+	}
+	return due
+}
+
+// errNothingDue ends a scheduled update that found nothing left to publish.
+var errNothingDue = errors.New("nothing is due to be published")
+
+// schedulePublishing sets a timer for the next scheduled record in c, the
+// catalog being set, replacing any earlier timer. Called with app.mu held.
+//
+// With the admin console the timer publishes due records in the catalog
+// file, which brings the change here as any edit does; that includes
+// records that were already due, such as after a restart. Without it the
+// server cannot change the API's data, so it only filters c again once
+// the time has come.
+func (app *App) schedulePublishing(c catalog, now time.Time) {
+	if app.publishTimer != nil {
+		app.publishTimer.Stop()
+	}
+	since := now
+	if app.store != nil {
+		since = time.Time{}
+	}
+	at, ok := c.nextPublishAt(since)
+	if !ok {
+		return
+	}
+	version := app.catalog.Version
+	app.publishTimer = time.AfterFunc(time.Until(at), func() {
+		if app.store == nil {
+			app.mu.Lock()
+			defer app.mu.Unlock()
+			// A newer catalog has taken this one's place.
+			if app.catalog.Version == version {
+				c.LoadedAt = time.Now()
+				app.applyCatalog(c)
+			}
+			return
+		}
+		err := app.store.update("scheduler", func(next *catalog) error {
+			if !publishDue(next, time.Now()) {
+				return errNothingDue
+			}
+			return nil
+		})
+		if err != nil && err != errNothingDue {
+			log.Printf("Failed to publish scheduled records, trying again in a minute: %v", err)
+			app.mu.Lock()
+			app.publishTimer = time.AfterFunc(time.Minute, func() { app.setCatalog(app.store.catalog()) })
+			app.mu.Unlock()
+		}
+	})
+}
+
+// publishAtLayout is how the forms show publish times, the format of a
+// datetime-local input, in the server's time zone.
+const publishAtLayout = "2006-01-02T15:04"
+
+func publicationFields() []formField {
+	options := make([]formOption, len(statuses))
+	for i, status := range statuses {
+		options[i] = formOption{Value: status, Label: status}
+	}
+	return []formField{
+		{Name: "status", Label: "Status", Type: "select", Options: options},
+		{Name: "publishAt", Label: "Publish at", Type: "datetime-local"},
+	}
+}
+
+// setPublication adds a record's status fields to its form values.
+func setPublication(values url.Values, status, publishAt string) url.Values {
+	if status == "" {
+		status = statusPublished
+	}
+	if at, err := time.Parse(time.RFC3339, publishAt); err == nil {
+		publishAt = at.In(time.Local).Format(publishAtLayout)
+	}
+	values.Set("status", status)
+	values.Set("publishAt", publishAt)
+	return values
+}
+
+// publication reads the status fields. Values without a status, such as an
+// import's, keep the record's current ones.
+func (f *formReader) publication(status, publishAt string) (string, string) {
+	if _, ok := f.values["status"]; !ok {
+		return status, publishAt
+	}
+	status = strings.TrimSpace(f.values.Get("status"))
+	switch {
+	case status == "" || status == statusPublished:
+		return "", ""
+	case !contains(statuses, status):
+		f.errs["status"] = "Status must be draft, scheduled, published or archived."
+		return "", ""
+	case status != statusScheduled:
+		return status, ""
+	}
+	v := strings.TrimSpace(f.values.Get("publishAt"))
+	at, err := time.ParseInLocation(publishAtLayout, v, time.Local)
+	if err != nil {
+		// Imported data.json files carry the stored RFC 3339 form.
+		if at, err = time.Parse(time.RFC3339, v); err != nil {
+			f.errs["publishAt"] = "Scheduled records need a publish date and time."
+		}
+	}
+	return status, at.UTC().Format(time.RFC3339)
+}
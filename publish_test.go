@@ -0,0 +1,122 @@
+package main
+
+import (
+	"cars/structs"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCatalogPublic(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := catalog{
+		Manufacturers: []structs.Manufacturer{
+			{ID: 1, Name: "Mercedes-Benz"},
+			{ID: 2, Name: "Toyota", Status: "draft"},
+		},
+		Categories: []structs.Category{{ID: 1, Name: "SUV"}, {ID: 2, Name: "Sedan", Status: "published"}},
+		CarModels: []structs.CarModel{
+			{ID: 1, Name: "GLE", ManufacturerID: 1, CategoryID: 1},
+			{ID: 2, Name: "E-Class", ManufacturerID: 1, CategoryID: 2, Status: "archived"},
+			{ID: 3, Name: "Corolla", ManufacturerID: 2, CategoryID: 2},
+			{ID: 4, Name: "GLC", ManufacturerID: 1, CategoryID: 1, Status: "scheduled", PublishAt: "2026-03-01T11:59:00Z"},
+			{ID: 5, Name: "G-Class", ManufacturerID: 1, CategoryID: 1, Status: "scheduled", PublishAt: "2026-03-01T12:30:00Z"},
+		},
+	}
+
+	public := c.public(now)
+	var ids []int
+	for _, car := range public.CarModels {
+		ids = append(ids, car.ID)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 4 {
+		t.Errorf("expected the published car and the one whose time has come, got %v", ids)
+	}
+	if len(public.Manufacturers) != 1 || len(public.Categories) != 2 {
+		t.Errorf("the draft manufacturer should be left out, got %+v", public.Manufacturers)
+	}
+	if at, ok := c.nextPublishAt(now); !ok || !at.Equal(now.Add(30*time.Minute)) {
+		t.Errorf("the next car is due at 12:30, got %v", at)
+	}
+	if !publishDue(&c, now) || c.CarModels[3].Status != "" || c.CarModels[4].Status != "scheduled" {
+		t.Errorf("only the car that is due should be published, got %+v", c.CarModels)
+	}
+}
+
+func TestPublishing_Drafts(t *testing.T) {
+	app, _ := setupAdminApp(t)
+
+	form := url.Values{"name": {"Toyota Corolla"}, "manufacturerId": {"2"}, "categoryId": {"2"}, "year": {"2023"}, "horsepower": {"139"}, "status": {"draft"}}
+	if rr := adminRequest(app, "POST", "/admin/cars/3", form); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected the draft to be saved, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+	for _, page := range []struct {
+		handler http.HandlerFunc
+		url     string
+	}{{app.indexHandler, "/"}, {app.searchHandler, "/search?q=corolla"}, {app.filterHandler, "/filter?manufacturer=2"}} {
+		if body := get(t, page.handler, page.url).Body.String(); strings.Contains(body, "Toyota Corolla") {
+			t.Errorf("%s should not show a draft", page.url)
+		}
+	}
+	if car, _ := app.snapshot().findCar(3); car != nil {
+		t.Errorf("drafts must stay out of the snapshot")
+	}
+	body := requestAs(app, "vera", "GET", "/admin", nil).Body.String()
+	if !strings.Contains(body, "Toyota Corolla") || !strings.Contains(body, "<td>draft</td>") {
+		t.Errorf("the console should list the draft")
+	}
+
+	// An import without a status column leaves it a draft.
+	csv := "id,name,year,manufacturer,category,horsepower\n1,Mercedes-Benz GLE,2022,Mercedes-Benz,SUV,362\n" +
+		"2,Mercedes-Benz E-Class,2023,Mercedes-Benz,Sedan,255\n3,Toyota Corolla,2023,Toyota,Sedan,140\n"
+	if rr := adminRequest(app, "POST", "/admin/import", url.Values{"data": {csv}, "digest": {importDigest(app.store.catalog(), []byte(csv))}, "apply": {"1"}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected the import to apply, got %d", rr.Code)
+	}
+	if car, _ := app.store.catalog().findCar(3); car == nil || car.Status != "draft" || car.Specifications.Horsepower != 140 {
+		t.Errorf("the import should keep the car a draft, got %+v", car)
+	}
+
+	form.Set("status", "scheduled")
+	form.Del("csrf")
+	rr := adminRequest(app, "POST", "/admin/cars/3", form)
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "Scheduled records need a publish date and time.") {
+		t.Errorf("scheduling needs a time, got %d", rr.Code)
+	}
+}
+
+func TestPublishing_Scheduled(t *testing.T) {
+	app, path := setupAdminApp(t)
+
+	at := time.Now().Add(100 * time.Millisecond).UTC().Format(time.RFC3339Nano)
+	err := app.store.update("eddie", func(c *catalog) error {
+		c.CarModels[2].Status, c.CarModels[2].PublishAt = "scheduled", at
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if car, _ := app.snapshot().findCar(3); car != nil {
+		t.Fatalf("a scheduled car should wait for its time")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if car, _ := app.snapshot().findCar(3); car != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the scheduled car was not published")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	saved, _ := loadCatalogFile(path)
+	if car, _ := saved.findCar(3); car.Status != "" || car.PublishAt != "" {
+		t.Errorf("the catalog file should record the car as published, got %+v", car)
+	}
+	entries, _ := app.store.audit.read(func(e auditEntry) bool { return e.Actor == "scheduler" })
+	if len(entries) != 1 || entries[0].ID != 3 {
+		t.Errorf("publishing should be audited, got %+v", entries)
+	}
+}
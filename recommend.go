@@ -0,0 +1,72 @@
+package main
+
+import (
+	"cars/structs"
+	"math"
+	"sort"
+)
+
+type scoredCar struct {
+	car      structs.CarModel
+	distance float64
+}
+
+// similarCars returns up to n models closest to car by the weighted distance
+// configured in weights. Horsepower and year differences are scaled by their
+// spread across the catalog so every weight works on a 0..1 range.
+func (c catalog) similarCars(car structs.CarModel, n int, weights SimilarityWeights) []structs.CarModel {
+	if n <= 0 || len(c.CarModels) < 2 {
+		return nil
+	}
+
+	minHP, maxHP := car.Specifications.Horsepower, car.Specifications.Horsepower
+	minYear, maxYear := car.Year, car.Year
+	for _, other := range c.CarModels {
+		minHP = min(minHP, other.Specifications.Horsepower)
+		maxHP = max(maxHP, other.Specifications.Horsepower)
+		minYear = min(minYear, other.Year)
+		maxYear = max(maxYear, other.Year)
+	}
+	hpSpan := float64(max(maxHP-minHP, 1))
+	yearSpan := float64(max(maxYear-minYear, 1))
+
+	country := c.getCountryByManufacturerID(car.ManufacturerID)
+
+	var scored []scoredCar
+	for _, other := range c.CarModels {
+		if other.ID == car.ID {
+			continue
+		}
+
+		distance := 0.0
+		if other.CategoryID != car.CategoryID {
+			distance += weights.Category
+		}
+		distance += weights.Horsepower * math.Abs(float64(other.Specifications.Horsepower-car.Specifications.Horsepower)) / hpSpan
+		distance += weights.Year * math.Abs(float64(other.Year-car.Year)) / yearSpan
+		if other.Specifications.Drivetrain != car.Specifications.Drivetrain {
+			distance += weights.Drivetrain
+		}
+		if c.getCountryByManufacturerID(other.ManufacturerID) != country {
+			distance += weights.Country
+		}
+
+		scored = append(scored, scoredCar{car: other, distance: distance})
+	}
+
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].distance != scored[j].distance {
+			return scored[i].distance < scored[j].distance
+		}
+		return scored[i].car.ID < scored[j].car.ID
+	})
+
+	if len(scored) > n {
+		scored = scored[:n]
+	}
+	similar := make([]structs.CarModel, len(scored))
+	for i, s := range scored {
+		similar[i] = s.car
+	}
+	return similar
+}
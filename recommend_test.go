@@ -0,0 +1,83 @@
+package main
+
+import (
+	"cars/structs"
+	"testing"
+)
+
+func setupRecommendApp() *App {
+	return &App{
+		catalog: catalog{
+			Manufacturers: []structs.Manufacturer{
+				{ID: 1, Name: "Toyota", Country: "Japan"},
+				{ID: 2, Name: "BMW", Country: "Germany"},
+			},
+			CarModels: []structs.CarModel{
+				{ID: 1, Name: "Sedan A", ManufacturerID: 1, CategoryID: 2, Year: 2023,
+					Specifications: structs.Specifications{Horsepower: 150, Drivetrain: "Front-Wheel Drive"}},
+				{ID: 2, Name: "Sedan B", ManufacturerID: 1, CategoryID: 2, Year: 2022,
+					Specifications: structs.Specifications{Horsepower: 160, Drivetrain: "Front-Wheel Drive"}},
+				{ID: 3, Name: "Sedan C", ManufacturerID: 2, CategoryID: 2, Year: 2023,
+					Specifications: structs.Specifications{Horsepower: 250, Drivetrain: "Rear-Wheel Drive"}},
+				{ID: 4, Name: "Truck D", ManufacturerID: 1, CategoryID: 4, Year: 2020,
+					Specifications: structs.Specifications{Horsepower: 400, Drivetrain: "Four-Wheel Drive"}},
+			},
+		},
+	}
+}
+
+func TestSimilarCars_OrderedByDistance(t *testing.T) {
+	app := setupRecommendApp()
+	weights := defaultConfig().Recommendations.Weights
+
+	similar := app.catalog.similarCars(app.catalog.CarModels[0], 3, weights)
+
+	var ids []int
+	for _, c := range similar {
+		ids = append(ids, c.ID)
+	}
+	want := []int{2, 3, 4}
+	if len(ids) != len(want) {
+		t.Fatalf("got %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("got %v, want %v", ids, want)
+			break
+		}
+	}
+}
+
+func TestSimilarCars_WeightsChangeRanking(t *testing.T) {
+	app := setupRecommendApp()
+	weights := SimilarityWeights{Category: 10}
+
+	similar := app.catalog.similarCars(app.catalog.CarModels[3], 1, weights)
+	if len(similar) != 1 || similar[0].ID != 1 {
+		t.Errorf("expected tie on category to fall back to lowest ID, got %+v", similar)
+	}
+
+	weights = SimilarityWeights{Horsepower: 1}
+	similar = app.catalog.similarCars(app.catalog.CarModels[3], 1, weights)
+	if len(similar) != 1 || similar[0].ID != 3 {
+		t.Errorf("expected closest horsepower to win, got %+v", similar)
+	}
+}
+
+func TestSimilarCars_ExcludesSelfAndRespectsCount(t *testing.T) {
+	app := setupRecommendApp()
+
+	similar := app.catalog.similarCars(app.catalog.CarModels[1], 10, defaultConfig().Recommendations.Weights)
+	if len(similar) != 3 {
+		t.Fatalf("expected 3 recommendations, got %d", len(similar))
+	}
+	for _, c := range similar {
+		if c.ID == app.catalog.CarModels[1].ID {
+			t.Errorf("car recommended itself")
+		}
+	}
+
+	if got := app.catalog.similarCars(app.catalog.CarModels[1], 0, defaultConfig().Recommendations.Weights); got != nil {
+		t.Errorf("expected no recommendations for n=0, got %+v", got)
+	}
+}
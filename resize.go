@@ -0,0 +1,61 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+)
+
+// resizeCover scales src to fill r in dst, cropping whatever overflows
+// around the centre, like CSS object-fit: cover. Each destination pixel is
+// the average of the source pixels it covers, which keeps downscaled photos
+// free of the jagged edges nearest-neighbour sampling leaves.
+func resizeCover(dst draw.Image, r image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	if r.Empty() || sb.Empty() {
+		return
+	}
+
+	// Work on a plain RGBA copy so pixels can be read straight from Pix.
+	rgba, ok := src.(*image.RGBA)
+	if !ok || rgba.Bounds().Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, sb.Dx(), sb.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, sb.Min, draw.Src)
+	}
+	sw, sh := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+	dw, dh := r.Dx(), r.Dy()
+
+	// The visible part of the source has the destination's aspect ratio.
+	cropW, cropH := sw, sw*dh/dw
+	if cropH > sh {
+		cropW, cropH = sh*dw/dh, sh
+	}
+	cropX, cropY := (sw-cropW)/2, (sh-cropH)/2
+
+	out := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := cropY + y*cropH/dh
+		y1 := max(cropY+(y+1)*cropH/dh, y0+1)
+		for x := 0; x < dw; x++ {
+			x0 := cropX + x*cropW/dw
+			x1 := max(cropX+(x+1)*cropW/dw, x0+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				i := sy*rgba.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					sum[0] += int(rgba.Pix[i])
+					sum[1] += int(rgba.Pix[i+1])
+					sum[2] += int(rgba.Pix[i+2])
+					sum[3] += int(rgba.Pix[i+3])
+					i += 4
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			o := y*out.Stride + x*4
+			for c := 0; c < 4; c++ {
+				out.Pix[o+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	draw.Draw(dst, r, out, image.Point{}, draw.Over)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// handle registers h for pattern on mux and records the pattern so
+// catchAllHandler can tell known paths from unknown ones. A "{name}" segment
+// matches any single non-empty segment and a trailing "*" matches the rest of
+// the path. Patterns sharing a prefix, such as "/cars/{id}" and
+// "/cars/{id}/{slug}", share one mux entry and so must share a handler.
+// Like http.ServeMux, it panics when pattern is registered twice.
+func (app *App) handle(mux *http.ServeMux, pattern string, h http.Handler) {
+	if app.muxPrefixes == nil {
+		app.muxPrefixes = make(map[string]bool)
+	}
+	for _, known := range app.routes {
+		if known == pattern {
+			panic("multiple registrations for " + pattern)
+		}
+	}
+	app.routes = append(app.routes, pattern)
+
+	prefix := pattern
+	if i := strings.IndexAny(pattern, "{*"); i >= 0 {
+		prefix = pattern[:i]
+	}
+	if app.muxPrefixes[prefix] {
+		return
+	}
+	app.muxPrefixes[prefix] = true
+	mux.Handle(prefix, h)
+}
+
+func (app *App) handleFunc(mux *http.ServeMux, pattern string, h http.HandlerFunc) {
+	app.handle(mux, pattern, h)
+}
+
+func (app *App) isKnownRoute(path string) bool {
+	for _, pattern := range app.routes {
+		if _, ok := matchRoute(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRoute reports whether path matches pattern and returns the values of
+// its "{name}" segments.
+func matchRoute(pattern, path string) (map[string]string, bool) {
+	if strings.HasSuffix(pattern, "*") {
+		return nil, strings.HasPrefix(path, strings.TrimSuffix(pattern, "*"))
+	}
+	if !strings.Contains(pattern, "{") {
+		return nil, pattern == path
+	}
+
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegments) != len(pathSegments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pathSegments[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(segment, "{}")] = pathSegments[i]
+			continue
+		}
+		if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
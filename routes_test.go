@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchRoute(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+		id            string
+	}{
+		{"/", "/", true, ""},
+		{"/", "/other", false, ""},
+		{"/static/*", "/static/styles.css", true, ""},
+		{"/cars/{id}/{slug}", "/cars/12/toyota-corolla-2023", true, "12"},
+		{"/cars/{id}/{slug}", "/cars/12", false, ""},
+		{"/cars/{id}", "/cars/12", true, "12"},
+		{"/cars/{id}", "/cars/", false, ""},
+		{"/cars/{id}/{slug}", "/cars/12/a/b", false, ""},
+	}
+	for _, tt := range tests {
+		params, ok := matchRoute(tt.pattern, tt.path)
+		if ok != tt.want {
+			t.Errorf("matchRoute(%q, %q) = %v, want %v", tt.pattern, tt.path, ok, tt.want)
+			continue
+		}
+		if tt.id != "" && params["id"] != tt.id {
+			t.Errorf("matchRoute(%q, %q) id = %q, want %q", tt.pattern, tt.path, params["id"], tt.id)
+		}
+	}
+}
+
+func TestHandle_PanicsOnDuplicatePattern(t *testing.T) {
+	app, mux := &App{}, http.NewServeMux()
+	app.handleFunc(mux, "/cars/{id}", app.CarDetailsHandler)
+	app.handleFunc(mux, "/cars/{id}/{slug}", app.CarDetailsHandler)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a pattern twice should panic")
+		}
+	}()
+	app.handleFunc(mux, "/cars/{id}", app.CarDetailsHandler)
+}
+
+func TestCarURL(t *testing.T) {
+	app := setupCatalogApp(t)
+	if got, want := carURL(app.catalog.CarModels[1]), "/cars/2/mercedes-benz-e-class-2023"; got != want {
+		t.Errorf("carURL = %q, want %q", got, want)
+	}
+}
+
+func TestCarDetailsHandler_Slugs(t *testing.T) {
+	app := setupCatalogApp(t)
+	canonical := "/cars/3/toyota-corolla-2023"
+
+	tests := []struct {
+		url      string
+		handler  http.HandlerFunc
+		status   int
+		location string
+	}{
+		{canonical, app.CarDetailsHandler, http.StatusOK, ""},
+		{"/cars/3/corolla", app.CarDetailsHandler, http.StatusMovedPermanently, canonical},
+		{"/cars/3", app.CarDetailsHandler, http.StatusMovedPermanently, canonical},
+		{"/car?id=3", app.legacyCarHandler, http.StatusMovedPermanently, canonical},
+		{"/cars/99/unknown", app.CarDetailsHandler, http.StatusNotFound, ""},
+		{"/car?id=99", app.legacyCarHandler, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req, rr := httptest.NewRequest("GET", tt.url, nil), httptest.NewRecorder()
+		tt.handler(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.url, tt.status, rr.Code)
+		}
+		if got := rr.Header().Get("Location"); got != tt.location {
+			t.Errorf("%s: expected Location %q, got %q", tt.url, tt.location, got)
+		}
+	}
+}
+
+func TestCatchAllHandler_PatternRoutes(t *testing.T) {
+	app := setupCatalogApp(t)
+	mux := http.NewServeMux()
+	app.handleFunc(mux, "/", app.indexHandler)
+	app.handleFunc(mux, "/cars/{id}", app.CarDetailsHandler)
+	app.handleFunc(mux, "/cars/{id}/{slug}", app.CarDetailsHandler)
+	handler := app.catchAllHandler(mux)
+
+	tests := map[string]int{
+		"/cars/3/toyota-corolla-2023": http.StatusOK,
+		"/cars/3":                     http.StatusMovedPermanently,
+		"/cars/3/a/b":                 http.StatusNotFound,
+		"/nonexistent":                http.StatusNotFound,
+	}
+	for url, want := range tests {
+		req, rr := httptest.NewRequest("GET", url, nil), httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != want {
+			t.Errorf("%s: expected status %d, got %d", url, want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"cars/structs"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// baseURL is the absolute origin used in sitemaps, feeds and share links.
+// The configured siteUrl wins. Otherwise it is taken from the request, but
+// only for a host in allowedHosts: these URLs end up in caches and search
+// engines, so a forged Host header must not choose them.
+func (app *App) baseURL(r *http.Request) (string, error) {
+	if app.config.SiteURL != "" {
+		return strings.TrimSuffix(app.config.SiteURL, "/"), nil
+	}
+	for _, host := range app.config.AllowedHosts {
+		if strings.EqualFold(host, r.Host) {
+			scheme := "http"
+			if app.isHTTPS(r) {
+				scheme = "https"
+			}
+			return scheme + "://" + r.Host, nil
+		}
+	}
+	return "", errBadRequest("This site is not served under that host.")
+}
+
+// linkBase is baseURL for pages that work without it: with no trusted
+// origin their links are left relative rather than naming the Host the
+// client sent.
+func (app *App) linkBase(r *http.Request) string {
+	base, _ := app.baseURL(r)
+	return base
+}
+
+// sitemapHandler lists the home page and every car, manufacturer and
+// category page. All entries share the snapshot's load time as lastmod,
+// since the API does not say when individual records changed.
+func (app *App) sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	base, err := app.baseURL(r)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+	c := app.snapshot()
+	lastMod := ""
+	if !c.LoadedAt.IsZero() {
+		lastMod = c.LoadedAt.UTC().Format(time.RFC3339)
+	}
+
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	add := func(path string) {
+		set.URLs = append(set.URLs, sitemapURL{Loc: base + path, LastMod: lastMod})
+	}
+	add("/")
+	for _, car := range c.CarModels {
+		add(carURL(car))
+	}
+	for _, m := range c.Manufacturers {
+		add(manufacturerURL(m.Name))
+	}
+	for _, category := range c.Categories {
+		add(categoryURL(category.Name))
+	}
+
+	app.writeXML(w, r, "application/xml; charset=utf-8", set)
+}
+
+func (app *App) robotsHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	base, err := app.baseURL(r)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "User-agent: *\nDisallow: /api/\nDisallow: /graphql\nDisallow: /export/\nDisallow: /search\nDisallow: /admin\n\nSitemap: %s/sitemap.xml\n", base)
+}
+
+// feedHandler publishes models that appeared between catalog loads while
+// this server has been running, newest first.
+func (app *App) feedHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	base, err := app.baseURL(r)
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+	c := app.snapshot()
+	arrivals := app.recentArrivals()
+
+	updated := c.LoadedAt
+	if len(arrivals) > 0 {
+		updated = arrivals[0].AddedAt
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	feed := atomFeed{
+		XMLNS:   "http://www.w3.org/2005/Atom",
+		ID:      base + "/feed.atom",
+		Title:   "Aurora cars: new models",
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "Aurora cars"},
+		Links: []atomLink{
+			{Href: base + "/feed.atom", Rel: "self", Type: "application/atom+xml"},
+			{Href: base + "/", Rel: "alternate", Type: "text/html"},
+		},
+	}
+
+	manufacturers := make(map[int]structs.Manufacturer, len(c.Manufacturers))
+	for _, m := range c.Manufacturers {
+		manufacturers[m.ID] = m
+	}
+	for _, a := range arrivals {
+		link := base + carURL(a.Car)
+		m := manufacturers[a.Car.ManufacturerID]
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      link,
+			Title:   fmt.Sprintf("%s (%d)", a.Car.Name, a.Car.Year),
+			Updated: a.AddedAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			Summary: fmt.Sprintf("New from %s: %d hp %s, %s.", m.Name, a.Car.Specifications.Horsepower, a.Car.Specifications.Engine, a.Car.Specifications.Drivetrain),
+		})
+	}
+
+	app.writeXML(w, r, "application/atom+xml; charset=utf-8", feed)
+}
+
+func (app *App) writeXML(w http.ResponseWriter, r *http.Request, contentType string, v interface{}) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		app.renderError(w, r, errInternal(err))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	w.Write(out)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"cars/structs"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSitemap(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.catalog.LoadedAt = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	req, rr := httptest.NewRequest("GET", "http://cars.example/sitemap.xml", nil), httptest.NewRecorder()
+	app.sitemapHandler(rr, req)
+
+	var set sitemapURLSet
+	if err := xml.Unmarshal(rr.Body.Bytes(), &set); err != nil {
+		t.Fatal(err)
+	}
+	var locs []string
+	for _, u := range set.URLs {
+		locs = append(locs, u.Loc)
+		if u.LastMod != "2024-05-01T12:00:00Z" {
+			t.Errorf("%s: lastmod should be the snapshot time, got %q", u.Loc, u.LastMod)
+		}
+	}
+	for _, want := range []string{
+		"http://cars.example/",
+		"http://cars.example/cars/3/toyota-corolla-2023",
+		"http://cars.example/manufacturers/mercedes-benz",
+		"http://cars.example/categories/suv",
+	} {
+		if !contains(locs, want) {
+			t.Errorf("sitemap is missing %s", want)
+		}
+	}
+	if len(locs) != 1+3+2+2 {
+		t.Errorf("expected 8 URLs, got %d", len(locs))
+	}
+}
+
+func TestRobots_PointsAtConfiguredSite(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.config.SiteURL = "https://aurora.example/"
+	req, rr := httptest.NewRequest("GET", "/robots.txt", nil), httptest.NewRecorder()
+	app.robotsHandler(rr, req)
+
+	if !strings.Contains(rr.Body.String(), "Sitemap: https://aurora.example/sitemap.xml") {
+		t.Errorf("unexpected robots.txt:\n%s", rr.Body.String())
+	}
+}
+
+func TestAbsoluteURLs_OnlyForTrustedHosts(t *testing.T) {
+	app := setupCatalogApp(t)
+
+	for _, page := range []struct {
+		handler http.HandlerFunc
+		url     string
+	}{
+		{app.sitemapHandler, "http://evil.example/sitemap.xml"},
+		{app.robotsHandler, "http://evil.example/robots.txt"},
+		{app.feedHandler, "http://evil.example/feed.atom"},
+	} {
+		rr := get(t, page.handler, page.url)
+		if rr.Code != http.StatusBadRequest || strings.Contains(rr.Body.String(), "http://evil.example/") {
+			t.Errorf("%s: expected 400 without the forged host, got %d", page.url, rr.Code)
+		}
+	}
+
+	// Pages are still served under other hosts, with relative links.
+	for _, page := range []struct {
+		handler http.HandlerFunc
+		url     string
+	}{
+		{app.CarDetailsHandler, "http://192.168.1.5:8080/cars/3/toyota-corolla-2023"},
+		{app.embedHandler, "http://192.168.1.5:8080/embed/car/3"},
+	} {
+		rr := get(t, page.handler, page.url)
+		if body := rr.Body.String(); rr.Code != http.StatusOK || strings.Contains(body, "192.168.1.5") || !strings.Contains(body, `<link rel="canonical" href="/cars/3/toyota-corolla-2023">`) {
+			t.Errorf("%s: expected the page with relative links, got %d:\n%s", page.url, rr.Code, body)
+		}
+	}
+	rr := get(t, app.oEmbedHandler, "http://192.168.1.5:8080/oembed?url="+url.QueryEscape("http://192.168.1.5:8080/cars/3"))
+	if !strings.Contains(rr.Body.String(), `src=\"http://192.168.1.5:8080/embed/car/3\"`) {
+		t.Errorf("oEmbed should link to the URL it was asked about, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "http://cars.example/robots.txt", nil)
+	rr = httptest.NewRecorder()
+	req.Header.Set("X-Forwarded-Proto", "https")
+	app.robotsHandler(rr, req)
+	if !strings.Contains(rr.Body.String(), "Sitemap: http://cars.example/sitemap.xml") {
+		t.Errorf("X-Forwarded-Proto should be ignored without trustProxy:\n%s", rr.Body.String())
+	}
+	app.config.TrustProxy = true
+	rr = httptest.NewRecorder()
+	app.robotsHandler(rr, req)
+	if !strings.Contains(rr.Body.String(), "Sitemap: https://cars.example/sitemap.xml") {
+		t.Errorf("X-Forwarded-Proto should count behind a trusted proxy:\n%s", rr.Body.String())
+	}
+}
+
+func TestSitemapAndRobots_AreKnownRoutes(t *testing.T) {
+	app := setupCatalogApp(t)
+	mux := http.NewServeMux()
+	app.handleFunc(mux, "/sitemap.xml", app.sitemapHandler)
+	app.handleFunc(mux, "/robots.txt", app.robotsHandler)
+	handler := app.catchAllHandler(mux)
+
+	for _, path := range []string{"/sitemap.xml", "/robots.txt"} {
+		req, rr := httptest.NewRequest("GET", path, nil), httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestFeed_ListsModelsAddedBetweenLoads(t *testing.T) {
+	app := setupCatalogApp(t)
+	first := app.snapshot()
+	first.LoadedAt = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	app.setCatalog(first)
+	if len(app.recentArrivals()) != 0 {
+		t.Fatalf("the first load must not count as new models")
+	}
+
+	second := first
+	second.LoadedAt = first.LoadedAt.Add(time.Hour)
+	second.CarModels = append(append([]structs.CarModel{}, first.CarModels...),
+		structs.CarModel{ID: 4, Name: "Toyota Supra", ManufacturerID: 2, CategoryID: 2, Year: 2024})
+	app.setCatalog(second)
+
+	req, rr := httptest.NewRequest("GET", "http://cars.example/feed.atom", nil), httptest.NewRecorder()
+	app.feedHandler(rr, req)
+
+	var feed atomFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Entries) != 1 {
+		t.Fatalf("expected one entry, got %+v", feed.Entries)
+	}
+	entry := feed.Entries[0]
+	if entry.Title != "Toyota Supra (2024)" || entry.Link.Href != "http://cars.example/cars/4/toyota-supra-2024" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if entry.Updated != "2024-05-01T01:00:00Z" || feed.Updated != entry.Updated {
+		t.Errorf("entry and feed should be dated by the load that found the model, got %s and %s", entry.Updated, feed.Updated)
+	}
+
+	app.setCatalog(second)
+	if len(app.recentArrivals()) != 1 {
+		t.Errorf("reloading the same catalog must not add entries")
+	}
+}
+
+func TestAddArrivals_Capped(t *testing.T) {
+	var next []structs.CarModel
+	for i := 1; i <= maxArrivals+5; i++ {
+		next = append(next, structs.CarModel{ID: i})
+	}
+	arrivals := addArrivals(nil, nil, next, time.Now())
+	if len(arrivals) != maxArrivals || arrivals[0].Car.ID != maxArrivals+5 {
+		t.Errorf("expected the %d newest models first, got %d starting at %d", maxArrivals, len(arrivals), arrivals[0].Car.ID)
+	}
+}
+
+// TestCatalog_ReloadWhileServing is meant for go test -race: pages read the
+// catalog while it is being replaced.
+func TestCatalog_ReloadWhileServing(t *testing.T) {
+	app := setupCatalogApp(t)
+	c := app.snapshot()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			c.LoadedAt = time.Now()
+			app.setCatalog(c)
+		}
+	}()
+	for _, page := range []struct {
+		handler http.HandlerFunc
+		url     string
+	}{
+		{app.filterHandler, "/filter?manufacturer=1"},
+		{app.searchHandler, "/search?query=corolla"},
+		{app.compareHandler, "/compare?car_ids=1&car_ids=3"},
+		{app.CarDetailsHandler, "/cars/1"},
+		{app.manufacturerHandler, "/manufacturers/toyota"},
+		{app.countryHandler, "/countries/japan"},
+		{app.apiCarsHandler, "/api/v1/cars?embed=manufacturer,category"},
+		{app.graphQLHandler, "/graphql?query=%7Bcars%7Bname%20manufacturer%7Bname%7D%7D%7D"},
+	} {
+		for i := 0; i < 10; i++ {
+			if rr := get(t, page.handler, page.url); rr.Code >= 500 {
+				t.Fatalf("%s: got %d", page.url, rr.Code)
+			}
+		}
+	}
+	<-done
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"cars/structs"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// carURL returns the canonical address of a car, e.g.
+// "/cars/1/toyota-corolla-2023".
+func carURL(car structs.CarModel) string {
+	return fmt.Sprintf("/cars/%d/%s", car.ID, slugify(fmt.Sprintf("%s %d", car.Name, car.Year)))
+}
+
+// slugify lowercases s and collapses every run of non-alphanumeric
+// characters into a single hyphen, e.g. "Mercedes-Benz E-Class" becomes
+// "mercedes-benz-e-class".
+func slugify(s string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		pendingHyphen = true
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+// Updates the browse pages in place: changing a filter swaps in the grid and
+// filter panel rendered by /fragments/, and reaching the end of the grid
+// loads the next page. Without JavaScript the forms work as plain requests.
+(function () {
+    const PER_PAGE = 24;
+    const grid = document.getElementById('car-grid');
+    const facets = document.getElementById('facets');
+    if (!grid || !facets) {
+        return;
+    }
+
+    async function fetchFragment(url) {
+        const response = await fetch(url, { headers: { Accept: 'text/html' } });
+        if (!response.ok) {
+            throw new Error(url + ' returned ' + response.status);
+        }
+        return response.text();
+    }
+
+    let observer = null;
+    function watchSentinel() {
+        if (observer) {
+            observer.disconnect();
+        }
+        const sentinel = grid.querySelector('.grid-sentinel');
+        if (!sentinel || !('IntersectionObserver' in window)) {
+            return;
+        }
+        observer = new IntersectionObserver(async (entries) => {
+            if (!entries.some((entry) => entry.isIntersecting)) {
+                return;
+            }
+            observer.disconnect();
+            const html = await fetchFragment(sentinel.dataset.next);
+            sentinel.remove();
+            grid.insertAdjacentHTML('beforeend', html);
+            watchSentinel();
+        });
+        observer.observe(sentinel);
+    }
+
+    async function applyFilters(form) {
+        const params = new URLSearchParams();
+        for (const [name, value] of new FormData(form)) {
+            if (value !== '') {
+                params.set(name, value);
+            }
+        }
+        const pageParams = new URLSearchParams(params);
+        pageParams.set('per_page', PER_PAGE);
+
+        const [gridHTML, facetsHTML] = await Promise.all([
+            fetchFragment('/fragments/grid?' + pageParams),
+            fetchFragment('/fragments/facets?' + params),
+        ]);
+        grid.innerHTML = gridHTML;
+        facets.innerHTML = facetsHTML;
+        history.replaceState(null, '', '/filter?' + params);
+        watchSentinel();
+    }
+
+    facets.addEventListener('change', (event) => {
+        const form = event.target.closest('.filter-form');
+        if (form) {
+            applyFilters(form).catch(() => form.submit());
+        }
+    });
+
+    watchSentinel();
+})();
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"cars/structs"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// catalogStore is the admin console's copy of the catalog, kept in a file in
+// the Node API's data.json format. Changes are written to a temporary file
+// and renamed over the original, so neither this server nor the Node API can
+// ever read a half-written catalog.
+type catalogStore struct {
+	mu   sync.Mutex
+	path string
+	data catalog
+	// publish receives every new catalog while the store is still locked,
+	// so concurrent changes are published in the order they were written.
+	publish func(catalog)
+	// audit, when set, gets an entry for every record a change touches.
+	audit *auditLog
+}
+
+// catalogFile is the on-disk layout, in the key order data.json uses.
+type catalogFile struct {
+	Manufacturers []structs.Manufacturer `json:"manufacturers"`
+	Categories    []structs.Category     `json:"categories"`
+	CarModels     []structs.CarModel     `json:"carModels"`
+}
+
+func openStore(path string, publish func(catalog)) (*catalogStore, error) {
+	c, err := loadCatalogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &catalogStore{path: path, data: c, publish: publish}, nil
+}
+
+// catalog returns a copy of the stored catalog that the caller may modify.
+func (s *catalogStore) catalog() catalog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.clone()
+}
+
+// update applies change to a copy of the catalog and writes the result,
+// recording actor as the one who made it. The stored catalog is only
+// replaced once the file has been written, so a failed change or write
+// leaves both untouched. The audit log is written after the file, so it
+// never records a change that was not made; a change it could not record
+// is rolled back.
+func (s *catalogStore) update(actor string, change func(c *catalog) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.data.clone()
+	if err := change(&next); err != nil {
+		return err
+	}
+	if err := writeCatalogFile(s.path, next); err != nil {
+		return err
+	}
+	now := time.Now()
+	if s.audit != nil {
+		if err := s.audit.append(auditEntries(now, actor, diffCatalogs(s.data, next))); err != nil {
+			if rerr := writeCatalogFile(s.path, s.data); rerr != nil {
+				return fmt.Errorf("failed to write the audit log: %w; the change could not be undone either: %v", err, rerr)
+			}
+			return fmt.Errorf("failed to write the audit log: %w", err)
+		}
+	}
+	next.LoadedAt = now
+	s.data = next
+	if s.publish != nil {
+		s.publish(next.clone())
+	}
+	return nil
+}
+
+func (c catalog) clone() catalog {
+	c.Manufacturers = append([]structs.Manufacturer(nil), c.Manufacturers...)
+	c.Categories = append([]structs.Category(nil), c.Categories...)
+	c.CarModels = append([]structs.CarModel(nil), c.CarModels...)
+	return c
+}
+
+func writeCatalogFile(path string, c catalog) error {
+	data, err := encodeCatalogFile(c)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o644)
+}
+
+func encodeCatalogFile(c catalog) ([]byte, error) {
+	file := catalogFile{Manufacturers: c.Manufacturers, Categories: c.Categories}
+	for _, car := range c.CarModels {
+		// The manufacturer name is derived when the catalog is loaded.
+		car.ManufacturerName = ""
+		file.CarModels = append(file.CarModels, car)
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// writeFileAtomic replaces path with data by writing a temporary file next
+// to it and renaming it into place. An existing file keeps its permissions;
+// a new one gets perm.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	mode := perm
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
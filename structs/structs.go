@@ -5,6 +5,11 @@ type Manufacturer struct {
 	Name    string `json:"name"`
 	Country string `json:"country"`
 	Founded int    `json:"foundingYear"`
+	// Status is "draft", "scheduled", "published" or "archived", and empty
+	// means published. Scheduled records go live at PublishAt, an RFC 3339
+	// time.
+	Status    string `json:"status,omitempty"`
+	PublishAt string `json:"publishAt,omitempty"`
 }
 
 type Specifications struct {
@@ -23,29 +28,50 @@ type CarModel struct {
 	Specifications   Specifications `json:"specifications"`
 	Image            string         `json:"image"`
 	ManufacturerName string         `json:"manufacturerName,omitempty"`
+	Status           string         `json:"status,omitempty"`
+	PublishAt        string         `json:"publishAt,omitempty"`
 }
 
 type Category struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Status    string `json:"status,omitempty"`
+	PublishAt string `json:"publishAt,omitempty"`
 }
 
 type PageData struct {
-	Title                 string
-	Manufacturers         []Manufacturer
-	CarModels             []CarModel
-	FilteredCars          []CarModel
-	Categories            []Category
-	Countries             []string
-	Years                 []int
-	SelectedManufacturers []string
-	SelectedCategories    []string
-	SelectedYears         []string
-	SelectedCountries     []string
-	ManufacturersMap      map[string]string
-	Results               []CarModel
-	Query                 string
-	NoResults             bool
-	ErrorMessage          string
-	ManuMap               map[int]Manufacturer
+	Title                 string               `json:"title"`
+	Manufacturers         []Manufacturer       `json:"manufacturers,omitempty"`
+	CarModels             []CarModel           `json:"carModels"`
+	FilteredCars          []CarModel           `json:"filteredCars,omitempty"`
+	Categories            []Category           `json:"categories,omitempty"`
+	Countries             []string             `json:"countries,omitempty"`
+	Years                 []int                `json:"years,omitempty"`
+	SelectedManufacturers []string             `json:"selectedManufacturers,omitempty"`
+	SelectedCategories    []string             `json:"selectedCategories,omitempty"`
+	SelectedYears         []string             `json:"selectedYears,omitempty"`
+	SelectedCountries     []string             `json:"selectedCountries,omitempty"`
+	ManufacturersMap      map[string]string    `json:"manufacturersMap,omitempty"`
+	Results               []CarModel           `json:"results,omitempty"`
+	Query                 string               `json:"query,omitempty"`
+	NoResults             bool                 `json:"noResults,omitempty"`
+	NextPage              string               `json:"nextPage,omitempty"`
+	ManuMap               map[int]Manufacturer `json:"manufacturersById,omitempty"`
+}
+
+type LineupStats struct {
+	ModelCount        int
+	MinHorsepower     int
+	MaxHorsepower     int
+	AverageHorsepower int
+	Categories        []string
+	Drivetrains       []string
+	Transmissions     []string
+	FirstYear         int
+	LastYear          int
+}
+
+type ManufacturerShare struct {
+	Manufacturer Manufacturer
+	ModelCount   int
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"cars/structs"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// pageMeta feeds the share-preview tags in a page's <head>: OpenGraph,
+// Twitter card and schema.org JSON-LD. URLs are absolute because crawlers
+// resolve them without the page's context.
+type pageMeta struct {
+	Title       string
+	Description string
+	URL         string
+	Image       string
+	ImageAlt    string
+	ImageWidth  int
+	ImageHeight int
+	OEmbed      string
+	JSONLD      interface{}
+}
+
+type jsonLDCar struct {
+	Context                 string             `json:"@context"`
+	Type                    string             `json:"@type"`
+	Name                    string             `json:"name"`
+	URL                     string             `json:"url"`
+	Image                   string             `json:"image,omitempty"`
+	Description             string             `json:"description"`
+	ModelDate               string             `json:"modelDate"`
+	BodyType                string             `json:"bodyType,omitempty"`
+	Brand                   jsonLDBrand        `json:"brand"`
+	Manufacturer            jsonLDOrganization `json:"manufacturer"`
+	VehicleEngine           jsonLDEngine       `json:"vehicleEngine"`
+	VehicleTransmission     string             `json:"vehicleTransmission,omitempty"`
+	DriveWheelConfiguration string             `json:"driveWheelConfiguration,omitempty"`
+}
+
+type jsonLDBrand struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type jsonLDOrganization struct {
+	Type         string `json:"@type"`
+	Name         string `json:"name"`
+	FoundingDate string `json:"foundingDate,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+type jsonLDEngine struct {
+	Type        string                  `json:"@type"`
+	Name        string                  `json:"name,omitempty"`
+	EnginePower jsonLDQuantitativeValue `json:"enginePower"`
+}
+
+type jsonLDQuantitativeValue struct {
+	Type     string `json:"@type"`
+	Value    int    `json:"value"`
+	UnitCode string `json:"unitCode"`
+	UnitText string `json:"unitText"`
+}
+
+// carMeta describes a car page for search engines and link previews, with
+// URLs under base. category is the name of the car's category.
+func carMeta(base string, car structs.CarModel, manufacturer structs.Manufacturer, category string) pageMeta {
+	specs := car.Specifications
+	details := []string{strings.TrimSpace(fmt.Sprintf("%d hp %s", specs.Horsepower, specs.Engine))}
+	for _, detail := range []string{specs.Transmission, specs.Drivetrain} {
+		if detail != "" {
+			details = append(details, detail)
+		}
+	}
+	meta := pageMeta{
+		Title:       fmt.Sprintf("%s (%d)", car.Name, car.Year),
+		Description: fmt.Sprintf("%d %s by %s: %s.", car.Year, car.Name, manufacturer.Name, strings.Join(details, ", ")),
+		URL:         base + carURL(car),
+		ImageAlt:    car.Name,
+		ImageWidth:  ogImageWidth,
+		ImageHeight: ogImageHeight,
+	}
+	meta.Image = fmt.Sprintf("%s/og/car/%d.png", base, car.ID)
+	meta.OEmbed = base + "/oembed?format=json&url=" + url.QueryEscape(meta.URL)
+
+	ld := jsonLDCar{
+		Context:     "https://schema.org",
+		Type:        "Car",
+		Name:        car.Name,
+		URL:         meta.URL,
+		Description: meta.Description,
+		ModelDate:   strconv.Itoa(car.Year),
+		Brand:       jsonLDBrand{Type: "Brand", Name: manufacturer.Name},
+		Manufacturer: jsonLDOrganization{
+			Type:     "Organization",
+			Name:     manufacturer.Name,
+			Location: manufacturer.Country,
+		},
+		VehicleEngine: jsonLDEngine{
+			Type: "EngineSpecification",
+			Name: specs.Engine,
+			// BHP is the UN/CEFACT code for brake horsepower.
+			EnginePower: jsonLDQuantitativeValue{Type: "QuantitativeValue", Value: specs.Horsepower, UnitCode: "BHP", UnitText: "hp"},
+		},
+		VehicleTransmission:     specs.Transmission,
+		DriveWheelConfiguration: specs.Drivetrain,
+	}
+	if car.Image != "" {
+		ld.Image = base + "/img/" + car.Image
+	}
+	if manufacturer.Founded != 0 {
+		ld.Manufacturer.FoundingDate = strconv.Itoa(manufacturer.Founded)
+	}
+	if category != "" {
+		ld.BodyType = category
+	}
+	meta.JSONLD = ld
+	return meta
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCarPage_StructuredDataAndShareTags(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.catalog.CarModels[2].Image = "toyota_corolla.jpg"
+	app.catalog.CarModels[2].Specifications.Engine = "1.8L Inline-4"
+
+	req := httptest.NewRequest("GET", "http://cars.example/cars/3/toyota-corolla-2023", nil)
+	rr := httptest.NewRecorder()
+	app.CarDetailsHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+
+	for _, want := range []string{
+		`<meta property="og:title" content="Toyota Corolla (2023)">`,
+		`<meta property="og:url" content="http://cars.example/cars/3/toyota-corolla-2023">`,
+		`<meta property="og:image" content="http://cars.example/og/car/3.png">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`<link rel="canonical" href="http://cars.example/cars/3/toyota-corolla-2023">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("car page is missing %s", want)
+		}
+	}
+
+	match := regexp.MustCompile(`(?s)<script type="application/ld\+json">(.*?)</script>`).FindStringSubmatch(body)
+	if match == nil {
+		t.Fatal("car page has no JSON-LD")
+	}
+	var ld map[string]interface{}
+	if err := json.Unmarshal([]byte(match[1]), &ld); err != nil {
+		t.Fatalf("JSON-LD is not valid JSON: %v\n%s", err, match[1])
+	}
+	if ld["@type"] != "Car" || ld["@context"] != "https://schema.org" || ld["bodyType"] != "Sedan" || ld["modelDate"] != "2023" || ld["image"] != "http://cars.example/img/toyota_corolla.jpg" {
+		t.Errorf("unexpected JSON-LD %v", ld)
+	}
+	brand, _ := ld["brand"].(map[string]interface{})
+	engine, _ := ld["vehicleEngine"].(map[string]interface{})
+	power, _ := engine["enginePower"].(map[string]interface{})
+	if brand["name"] != "Toyota" || engine["name"] != "1.8L Inline-4" || power["value"] != float64(139) {
+		t.Errorf("unexpected brand or engine in JSON-LD %v", ld)
+	}
+}
+
+func TestCarPage_JSONLDIsEscaped(t *testing.T) {
+	app := setupCatalogApp(t)
+	app.catalog.CarModels[0].Name = `GLE </script><script>alert(1)</script>`
+
+	req, rr := httptest.NewRequest("GET", carURL(app.catalog.CarModels[0]), nil), httptest.NewRecorder()
+	app.CarDetailsHandler(rr, req)
+
+	if strings.Contains(rr.Body.String(), "<script>alert(1)") {
+		t.Error("car name must not be able to break out of the JSON-LD script")
+	}
+}
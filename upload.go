@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Uploads are capped before decoding, both in bytes and in pixels, so a
+// small file that decompresses into a huge image cannot exhaust memory.
+// Accepted photos are scaled down to fit maxImageEdge.
+const (
+	maxUploadBytes  = 10 << 20
+	maxUploadPixels = 40_000_000
+	minImageWidth   = 320
+	maxImageEdge    = 1600
+	jpegQuality     = 85
+)
+
+// processUpload checks that data is a JPEG or PNG photo and re-encodes it,
+// scaled down if needed. Re-encoding keeps only the pixels, so EXIF, GPS and
+// other metadata are dropped. The result is named after its content.
+func processUpload(data []byte) (name string, encoded []byte, err error) {
+	format := ""
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		format = "jpeg"
+	case "image/png":
+		format = "png"
+	default:
+		return "", nil, validationError{"photo": "Upload a JPEG or PNG photo."}
+	}
+
+	cfg, decodedFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decodedFormat != format {
+		return "", nil, validationError{"photo": "The photo could not be read. It may be damaged."}
+	}
+	if cfg.Width < minImageWidth {
+		return "", nil, validationError{"photo": fmt.Sprintf("The photo must be at least %d pixels wide.", minImageWidth)}
+	}
+	if cfg.Width*cfg.Height > maxUploadPixels {
+		return "", nil, validationError{"photo": fmt.Sprintf("The photo may have at most %d megapixels.", maxUploadPixels/1_000_000)}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, validationError{"photo": "The photo could not be read. It may be damaged."}
+	}
+
+	img = scaleToFit(img, maxImageEdge)
+	var buf bytes.Buffer
+	ext := ".png"
+	if format == "jpeg" {
+		ext = ".jpg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:8]) + ext, buf.Bytes(), nil
+}
+
+// scaleToFit shrinks img so neither side exceeds maxEdge, keeping its
+// aspect ratio. Smaller images are returned as they are.
+func scaleToFit(img image.Image, maxEdge int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxEdge && h <= maxEdge {
+		return img
+	}
+	if w >= h {
+		w, h = maxEdge, max(1, h*maxEdge/w)
+	} else {
+		w, h = max(1, w*maxEdge/h), maxEdge
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	resizeCover(dst, dst.Bounds(), img)
+	return dst
+}
+
+// adminImageHandler takes a photo upload for a car, stores it in imageDir
+// and makes it the car's image.
+func (app *App) adminImageHandler(w http.ResponseWriter, r *http.Request, idStr string) {
+	if !app.allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	values, found := adminResources["cars"].values(app.store.catalog(), id)
+	if err != nil || !found {
+		app.renderError(w, r, errNotFound(fmt.Sprintf("There is no car with ID %s.", idStr)))
+		return
+	}
+
+	err = app.attachUpload(r, id)
+	var invalid validationError
+	if errors.As(err, &invalid) {
+		app.renderAdminForm(w, r, http.StatusUnprocessableEntity, "cars", id, values, invalid)
+		return
+	}
+	if err != nil {
+		app.renderError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/cars/"+url.PathEscape(idStr), http.StatusSeeOther)
+}
+
+func (app *App) attachUpload(r *http.Request, carID int) error {
+	file, _, err := r.FormFile("photo")
+	if err != nil {
+		return validationError{"photo": "Choose a photo to upload."}
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	name, encoded, err := processUpload(data)
+	if err != nil {
+		return err
+	}
+	// The same content always gets the same name, so an existing file
+	// already holds these bytes.
+	path := filepath.Join(imageDir, name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := os.MkdirAll(imageDir, 0o755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(path, encoded, 0o644); err != nil {
+			return err
+		}
+	}
+
+	return app.store.update(sessionFrom(r).Username, func(c *catalog) error {
+		for i := range c.CarModels {
+			if c.CarModels[i].ID == carID {
+				c.CarModels[i].Image = name
+				return nil
+			}
+		}
+		return errNotFound("Car not found.")
+	})
+}
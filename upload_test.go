@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// uploadAs posts data as the photo for car 1 with username's session. An
+// empty csrf sends the session's token.
+func uploadAs(t *testing.T, app *App, username string, data []byte, csrf string) *httptest.ResponseRecorder {
+	return postFileAs(t, app, username, "/admin/cars/1/image", "photo", data, csrf)
+}
+
+// postFileAs posts data as a multipart file field with username's session.
+func postFileAs(t *testing.T, app *App, username, target, field string, data []byte, csrf string) *httptest.ResponseRecorder {
+	t.Helper()
+	acct, _ := app.accounts.get(username)
+	rec := httptest.NewRecorder()
+	app.setSession(rec, httptest.NewRequest("GET", "/admin/login", nil), acct)
+	cookie := rec.Result().Cookies()[0]
+	if csrf == "" {
+		probe := httptest.NewRequest("GET", "/admin", nil)
+		probe.AddCookie(cookie)
+		s, _ := app.currentSession(probe)
+		csrf = app.csrfToken(s)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("csrf", csrf)
+	part, _ := mw.CreateFormFile(field, "upload.bin")
+	part.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", target, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.AddCookie(cookie)
+	rr := httptest.NewRecorder()
+	app.adminHandler(rr, req)
+	return rr
+}
+
+func setupUploadApp(t *testing.T) *App {
+	t.Helper()
+	app, _ := setupAdminApp(t)
+	dir := imageDir
+	imageDir = t.TempDir()
+	t.Cleanup(func() { imageDir = dir })
+	return app
+}
+
+func testPhoto(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+func TestUpload_ResizesAndAttaches(t *testing.T) {
+	app := setupUploadApp(t)
+	var buf bytes.Buffer
+	png.Encode(&buf, testPhoto(2400, 1200))
+
+	rr := uploadAs(t, app, "eddie", buf.Bytes(), "")
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/cars/1" {
+		t.Fatalf("expected a redirect to the car, got %d: %s", rr.Code, rr.Body.String())
+	}
+	car, _ := app.snapshot().findCar(1)
+	if car == nil || !regexp.MustCompile(`^[0-9a-f]{16}\.png$`).MatchString(car.Image) {
+		t.Fatalf("the car should point at a content-named file, got %+v", car)
+	}
+	f, err := os.Open(filepath.Join(imageDir, car.Image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil || format != "png" || cfg.Width != maxImageEdge || cfg.Height != maxImageEdge/2 {
+		t.Errorf("expected a %dx%d png, got %s %dx%d (%v)", maxImageEdge, maxImageEdge/2, format, cfg.Width, cfg.Height, err)
+	}
+
+	// The same photo again keeps the same name.
+	previous := car.Image
+	uploadAs(t, app, "eddie", buf.Bytes(), "")
+	if car, _ := app.snapshot().findCar(1); car.Image != previous {
+		t.Errorf("identical uploads should share a file, got %s and %s", previous, car.Image)
+	}
+}
+
+func TestUpload_StripsMetadata(t *testing.T) {
+	app := setupUploadApp(t)
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, testPhoto(400, 300), nil)
+	// Splice an EXIF segment in right after the SOI marker.
+	exif := append([]byte{0xFF, 0xE1, 0x00, 0x10}, []byte("Exif\x00\x00GPS-here")...)
+	data := append(append([]byte{}, buf.Bytes()[:2]...), append(exif, buf.Bytes()[2:]...)...)
+
+	if rr := uploadAs(t, app, "eddie", data, ""); rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected the upload to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	car, _ := app.snapshot().findCar(1)
+	stored, err := os.ReadFile(filepath.Join(imageDir, car.Image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(car.Image, ".jpg") || bytes.Contains(stored, []byte("Exif")) || bytes.Contains(stored, []byte("GPS-here")) {
+		t.Errorf("the stored jpeg should carry no metadata")
+	}
+}
+
+func TestUpload_Rejects(t *testing.T) {
+	app := setupUploadApp(t)
+	before, _ := app.snapshot().findCar(1)
+	var gifData, small bytes.Buffer
+	gif.Encode(&gifData, testPhoto(400, 300), nil)
+	png.Encode(&small, testPhoto(100, 100))
+
+	for name, tc := range map[string]struct {
+		data []byte
+		want string
+	}{
+		"text":  {[]byte("just some text"), "Upload a JPEG or PNG photo."},
+		"gif":   {gifData.Bytes(), "Upload a JPEG or PNG photo."},
+		"small": {small.Bytes(), "The photo must be at least 320 pixels wide."},
+		"fake":  {append([]byte("\x89PNG\r\n\x1a\n"), "not really"...), "The photo could not be read."},
+	} {
+		rr := uploadAs(t, app, "eddie", tc.data, "")
+		if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), tc.want) {
+			t.Errorf("%s: expected 422 with %q, got %d", name, tc.want, rr.Code)
+		}
+	}
+
+	if rr := uploadAs(t, app, "eddie", make([]byte, maxUploadBytes+128<<10), ""); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversize uploads: expected 413, got %d", rr.Code)
+	}
+	if rr := uploadAs(t, app, "eddie", small.Bytes(), "forged"); rr.Code != http.StatusForbidden {
+		t.Errorf("uploads need a CSRF token, got %d", rr.Code)
+	}
+	if rr := uploadAs(t, app, "vera", small.Bytes(), ""); rr.Code != http.StatusForbidden {
+		t.Errorf("viewers must not upload, got %d", rr.Code)
+	}
+
+	if files, _ := os.ReadDir(imageDir); len(files) > 0 {
+		t.Errorf("rejected uploads should not be stored, found %d files", len(files))
+	}
+	if car, _ := app.snapshot().findCar(1); car.Image != before.Image {
+		t.Errorf("rejected uploads should leave the car alone, got %s", car.Image)
+	}
+}